@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"ai-test/config"
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// ApiKeyHeader carries a static API key, the ApiKey authorization scheme is
+// accepted as well.
+const ApiKeyHeader = "X-API-Key"
+
+type apiKeys struct{}
+
+func (apiKeys) Authenticate(c fiber.Ctx, conf config.AuthConfig) (*User, error) {
+	key := c.Get(ApiKeyHeader)
+	if key == "" {
+		key = bearer(c, "ApiKey")
+	}
+	if key == "" {
+		return nil, nil
+	}
+
+	for _, candidate := range conf.ApiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(candidate.Key)) == 1 {
+			return &User{
+				Id:     candidate.Name,
+				Name:   candidate.Name,
+				Method: MethodApiKey,
+				Admin:  candidate.Admin,
+			}, nil
+		}
+	}
+
+	return nil, errInvalidCredentials
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"ai-test/config"
+	"ai-test/server/errors"
+	"context"
+	stderrors "errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	MethodApiKey    = "api_key"
+	MethodJWT       = "jwt"
+	MethodAnonymous = "anonymous"
+)
+
+// User is the caller of a request. Id is what quotas and logs refer to the
+// caller by.
+type User struct {
+	Id     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Method string `json:"method"`
+	Admin  bool   `json:"admin"`
+}
+
+// String identifies the user in log lines.
+func (u User) String() string {
+	return u.Method + ":" + u.Id
+}
+
+// Authenticator recognizes the callers using one kind of credentials. It
+// returns nil without error when the request has no such credentials, so the
+// next authenticator can be tried.
+type Authenticator interface {
+	Authenticate(c fiber.Ctx, conf config.AuthConfig) (*User, error)
+}
+
+var authenticators = []Authenticator{apiKeys{}, bearerTokens{}}
+
+var errInvalidCredentials = stderrors.New("invalid credentials")
+
+type userKey struct{}
+
+// WithUser returns a copy of ctx carrying the user, for code further down
+// the call chain that has no access to the request.
+func WithUser(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// FromContext returns the user of the request ctx belongs to.
+func FromContext(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(userKey{}).(User)
+	return user, ok
+}
+
+// authenticated makes the user available through both UserOf and
+// FromContext.
+func authenticated(c fiber.Ctx, user User) error {
+	c.Locals(userKey{}, user)
+	c.SetContext(WithUser(c.Context(), user))
+	return c.Next()
+}
+
+// New returns the middleware authenticating every request to the group it
+// is used on, except the public paths. With authentication disabled every
+// caller is anonymous, identified by IP address, and nobody is an
+// administrator.
+func New(public ...string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		conf := config.Get().Auth
+
+		if !conf.Enabled {
+			return authenticated(c, User{Id: c.IP(), Method: MethodAnonymous})
+		}
+
+		for _, path := range public {
+			if c.Path() == path {
+				return authenticated(c, User{Id: c.IP(), Method: MethodAnonymous})
+			}
+		}
+
+		for _, authenticator := range authenticators {
+			user, err := authenticator.Authenticate(c, conf)
+			if err != nil {
+				log.Warnf("Rejected credentials for %s %s from %s: %v", c.Method(), c.Path(), c.IP(), err)
+				unauthorized(c)
+				return nil
+			}
+			if user != nil {
+				return authenticated(c, *user)
+			}
+		}
+
+		unauthorized(c)
+		return nil
+	}
+}
+
+// RequireAdmin only lets administrators through, it must run after New.
+func RequireAdmin() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if !UserOf(c).Admin {
+			errors.ForbiddenError.Send(c)
+			return nil
+		}
+		return c.Next()
+	}
+}
+
+// UserOf returns the authenticated caller of a request.
+func UserOf(c fiber.Ctx) User {
+	user, ok := c.Locals(userKey{}).(User)
+	if !ok {
+		return User{Id: c.IP(), Method: MethodAnonymous}
+	}
+	return user
+}
+
+// FromRequest returns the user of a request served by a net/http handler,
+// which sees the locals of the request through its context.
+func FromRequest(r *http.Request) User {
+	user, ok := FromContext(r.Context())
+	if !ok {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		return User{Id: host, Method: MethodAnonymous}
+	}
+	return user
+}
+
+func unauthorized(c fiber.Ctx) {
+	c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="api"`)
+	errors.UnauthorizedError.Send(c)
+}
+
+// bearer returns the token of an Authorization header with the given scheme.
+func bearer(c fiber.Ctx, scheme string) string {
+	s, token, found := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !found || !strings.EqualFold(s, scheme) {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	// jwksRefresh is how long fetched keys are used before fetching them
+	// again.
+	jwksRefresh = time.Hour
+	// jwksRetry rate limits refetching when a token is signed by an unknown
+	// key, e.g. right after the issuer rotated its keys.
+	jwksRetry = time.Minute
+	// jwksBackoff is how long to wait after a failed fetch, doubling with
+	// every further failure up to jwksRefresh.
+	jwksBackoff = 5 * time.Second
+)
+
+var errUnknownKey = errors.New("token is signed by an unknown key")
+
+// keySet caches the keys of one JWKS location, an http(s):// or file:// URL.
+// Keys are fetched in the background, one fetch at a time, and kept when a
+// refresh fails.
+type keySet struct {
+	location string
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetched   time.Time
+	nextFetch time.Time
+	failures  int
+	err       error
+	// fetching is closed once the running fetch is over, nil when none is.
+	fetching chan struct{}
+}
+
+var (
+	keySetsMu sync.Mutex
+	keySets   = map[string]*keySet{}
+)
+
+func keySetFor(location string) *keySet {
+	keySetsMu.Lock()
+	defer keySetsMu.Unlock()
+
+	set, ok := keySets[location]
+	if !ok {
+		set = &keySet{location: location}
+		keySets[location] = set
+	}
+	return set
+}
+
+// key returns the key with the given id. An empty id matches the only key of
+// the set.
+func (s *keySet) key(ctx context.Context, id string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+
+	key, known := s.lookup(id)
+	if known && time.Since(s.fetched) <= jwksRefresh {
+		s.mu.Unlock()
+		return key, nil
+	}
+
+	if time.Now().Before(s.nextFetch) {
+		err := s.unknown()
+		s.mu.Unlock()
+		if known {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	done := s.refresh()
+	s.mu.Unlock()
+
+	// A stale key is used until the refresh replaces it.
+	if known {
+		return key, nil
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.lookup(id); ok {
+		return key, nil
+	}
+	return nil, s.unknown()
+}
+
+// unknown explains why a key is not in the set, s.mu must be held.
+func (s *keySet) unknown() error {
+	if s.err != nil {
+		return fmt.Errorf("fetching JWKS: %w", s.err)
+	}
+	return errUnknownKey
+}
+
+// refresh starts fetching the keys unless a fetch is running already, s.mu
+// must be held. The returned channel is closed once the fetch is over. The
+// fetch is not bound to the request asking for it, others may wait for it
+// too.
+func (s *keySet) refresh() <-chan struct{} {
+	if s.fetching != nil {
+		return s.fetching
+	}
+
+	done := make(chan struct{})
+	s.fetching = done
+
+	go func() {
+		keys, err := fetchKeys(context.Background(), s.location)
+
+		s.mu.Lock()
+		if err != nil {
+			s.failures++
+			s.err = err
+			s.nextFetch = time.Now().Add(min(jwksBackoff<<min(s.failures-1, 16), jwksRefresh))
+			log.Warnf("Could not fetch JWKS from %s (attempt %d), keeping %d known key(s): %v", s.location, s.failures, len(s.keys), err)
+		} else {
+			s.keys, s.fetched = keys, time.Now()
+			s.failures, s.err = 0, nil
+			s.nextFetch = s.fetched.Add(jwksRetry)
+		}
+		s.fetching = nil
+		s.mu.Unlock()
+
+		close(done)
+	}()
+
+	return done
+}
+
+func (s *keySet) lookup(id string) (crypto.PublicKey, bool) {
+	if id == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+
+	key, ok := s.keys[id]
+	return key, ok
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+func fetchKeys(ctx context.Context, location string) (map[string]crypto.PublicKey, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if u.Scheme == "file" {
+		body, err = os.ReadFile(u.Path)
+	} else {
+		body, err = fetch(ctx, location)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var document struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range document.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		// Issuers publish keys of types this server does not support, like
+		// Ed25519, next to the ones it does. Tokens signed by those fail
+		// as signed by an unknown key.
+		key, err := k.publicKey()
+		if err != nil {
+			log.Warnf("Skipping JWKS key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no usable signing key")
+	}
+	return keys, nil
+}
+
+func fetch(ctx context.Context, location string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, 1<<20))
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"ai-test/config"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// leeway tolerates clock skew between the issuer and this server.
+const leeway = time.Minute
+
+type algorithm struct {
+	hash crypto.Hash
+	// family is RS (PKCS #1 v1.5), PS (RSA-PSS) or ES (ECDSA).
+	family string
+	// curve is the only curve an ES algorithm is used with.
+	curve string
+}
+
+// Only asymmetric algorithms are accepted, "none" and HMAC never are.
+var algorithms = map[string]algorithm{
+	"RS256": {crypto.SHA256, "RS", ""},
+	"RS384": {crypto.SHA384, "RS", ""},
+	"RS512": {crypto.SHA512, "RS", ""},
+	"PS256": {crypto.SHA256, "PS", ""},
+	"PS384": {crypto.SHA384, "PS", ""},
+	"PS512": {crypto.SHA512, "PS", ""},
+	"ES256": {crypto.SHA256, "ES", "P-256"},
+	"ES384": {crypto.SHA384, "ES", "P-384"},
+	"ES512": {crypto.SHA512, "ES", "P-521"},
+}
+
+type claims map[string]any
+
+// verifyJWT checks the signature of a compact JWS against the configured
+// JWKS and validates its registered claims.
+func verifyJWT(ctx context.Context, conf config.OIDCConfig, token string) (claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+
+	alg, ok := algorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+
+	key, err := keySetFor(conf.JwksUrl).key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	h := alg.hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if err := verifySignature(alg, key, h.Sum(nil), signature); err != nil {
+		return nil, err
+	}
+
+	c := claims{}
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+	return c, c.validate(conf)
+}
+
+func verifySignature(alg algorithm, key crypto.PublicKey, digest []byte, signature []byte) error {
+	invalid := errors.New("invalid signature")
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		var err error
+		switch alg.family {
+		case "RS":
+			err = rsa.VerifyPKCS1v15(key, alg.hash, digest, signature)
+		case "PS":
+			err = rsa.VerifyPSS(key, alg.hash, digest, signature, nil)
+		default:
+			return fmt.Errorf("%s key used with an %s algorithm", "RSA", alg.family)
+		}
+		if err != nil {
+			return invalid
+		}
+		return nil
+
+	case *ecdsa.PublicKey:
+		if alg.family != "ES" {
+			return fmt.Errorf("%s key used with an %s algorithm", "EC", alg.family)
+		}
+		if name := key.Curve.Params().Name; name != alg.curve {
+			return fmt.Errorf("%s key used with an algorithm for %s", name, alg.curve)
+		}
+
+		// JWS encodes ECDSA signatures as r || s, not ASN.1.
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return invalid
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return invalid
+		}
+		return nil
+	}
+
+	return errors.New("unsupported key")
+}
+
+func (c claims) validate(conf config.OIDCConfig) error {
+	now := time.Now()
+
+	exp, ok := c.time("exp")
+	if !ok {
+		return errors.New("token has no expiry")
+	}
+	if now.After(exp.Add(leeway)) {
+		return errors.New("token is expired")
+	}
+	if nbf, ok := c.time("nbf"); ok && now.Add(leeway).Before(nbf) {
+		return errors.New("token is not valid yet")
+	}
+
+	if conf.Issuer == "" || c.string("iss") != conf.Issuer {
+		return fmt.Errorf("unexpected issuer %q", c.string("iss"))
+	}
+	if conf.Audience == "" || !slices.Contains(c.strings("aud"), conf.Audience) {
+		return errors.New("token is not meant for this audience")
+	}
+	return nil
+}
+
+func (c claims) time(name string) (time.Time, bool) {
+	seconds, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+func (c claims) string(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// strings reads a claim holding either a list or a space separated string,
+// like aud or scope.
+func (c claims) strings(name string) []string {
+	switch value := c[name].(type) {
+	case string:
+		return strings.Fields(value)
+	case []any:
+		var values []string
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
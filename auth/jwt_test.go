@@ -0,0 +1,485 @@
+package auth
+
+import (
+	"ai-test/config"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// issuer is a local OIDC issuer publishing its keys as a file:// JWKS.
+type issuer struct {
+	path string
+	rsa  *rsa.PrivateKey
+	p256 *ecdsa.PrivateKey
+	p384 *ecdsa.PrivateKey
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := &issuer{
+		path: filepath.Join(t.TempDir(), "jwks.json"),
+		rsa:  rsaKey,
+		p256: p256,
+		p384: p384,
+	}
+	i.publish(t, map[string]crypto.PublicKey{
+		"rsa":  &rsaKey.PublicKey,
+		"p256": &p256.PublicKey,
+		"p384": &p384.PublicKey,
+	})
+	return i
+}
+
+func (i *issuer) publish(t *testing.T, keys map[string]crypto.PublicKey) {
+	t.Helper()
+
+	var document struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, key := range keys {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			document.Keys = append(document.Keys, jwk{
+				Kid: kid,
+				Kty: "RSA",
+				Use: "sig",
+				N:   encodeInt(key.N),
+				E:   encodeInt(big.NewInt(int64(key.E))),
+			})
+		case *ecdsa.PublicKey:
+			size := (key.Curve.Params().BitSize + 7) / 8
+			document.Keys = append(document.Keys, jwk{
+				Kid: kid,
+				Kty: "EC",
+				Use: "sig",
+				Crv: key.Curve.Params().Name,
+				X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+				Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+			})
+		}
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(i.path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (i *issuer) conf() config.OIDCConfig {
+	return config.OIDCConfig{
+		Issuer:   "https://issuer.example",
+		Audience: "ing-portal",
+		JwksUrl:  "file://" + i.path,
+	}
+}
+
+func encodeInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func segment(t *testing.T, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sign builds a compact JWS with the given header algorithm and key id,
+// signed by key with the algorithm the key is meant for.
+func sign(t *testing.T, alg string, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+
+	input := segment(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + segment(t, claims)
+
+	hash := crypto.SHA256
+	if a, ok := algorithms[alg]; ok {
+		hash = a.hash
+	}
+	h := hash.New()
+	h.Write([]byte(input))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	var err error
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if strings.HasPrefix(alg, "PS") {
+			signature, err = rsa.SignPSS(rand.Reader, key, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest)
+		if err == nil {
+			size := (key.Curve.Params().BitSize + 7) / 8
+			signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims(overrides map[string]any) map[string]any {
+	now := time.Now()
+	claims := map[string]any{
+		"sub": "alice",
+		"iss": "https://issuer.example",
+		"aud": "ing-portal",
+		"exp": now.Add(time.Hour).Unix(),
+		"nbf": now.Add(-time.Minute).Unix(),
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+	return claims
+}
+
+func TestVerifyJWT(t *testing.T) {
+	i := newIssuer(t)
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		token     func(t *testing.T) string
+		configure func(conf *config.OIDCConfig)
+		valid     bool
+	}{
+		{
+			name:  "RS256",
+			token: func(t *testing.T) string { return sign(t, "RS256", "rsa", i.rsa, validClaims(nil)) },
+			valid: true,
+		},
+		{
+			name:  "RS512",
+			token: func(t *testing.T) string { return sign(t, "RS512", "rsa", i.rsa, validClaims(nil)) },
+			valid: true,
+		},
+		{
+			name:  "PS256",
+			token: func(t *testing.T) string { return sign(t, "PS256", "rsa", i.rsa, validClaims(nil)) },
+			valid: true,
+		},
+		{
+			name:  "ES256",
+			token: func(t *testing.T) string { return sign(t, "ES256", "p256", i.p256, validClaims(nil)) },
+			valid: true,
+		},
+		{
+			name:  "ES384",
+			token: func(t *testing.T) string { return sign(t, "ES384", "p384", i.p384, validClaims(nil)) },
+			valid: true,
+		},
+		{
+			name:  "none algorithm",
+			token: func(t *testing.T) string { return sign(t, "none", "rsa", i.rsa, validClaims(nil)) },
+		},
+		{
+			name:  "HMAC algorithm",
+			token: func(t *testing.T) string { return sign(t, "HS256", "rsa", i.rsa, validClaims(nil)) },
+		},
+		{
+			name:  "RSA algorithm with an EC key",
+			token: func(t *testing.T) string { return sign(t, "RS256", "p256", i.rsa, validClaims(nil)) },
+		},
+		{
+			name:  "EC algorithm with an RSA key",
+			token: func(t *testing.T) string { return sign(t, "ES256", "rsa", i.p256, validClaims(nil)) },
+		},
+		{
+			name: "PKCS #1 signature as PSS",
+			token: func(t *testing.T) string {
+				return relabel(t, sign(t, "RS256", "rsa", i.rsa, validClaims(nil)), "PS256")
+			},
+		},
+		{
+			name:  "ES256 signature by another curve",
+			token: func(t *testing.T) string { return sign(t, "ES256", "p256", i.p384, validClaims(nil)) },
+		},
+		{
+			name:  "ES384 with a P-256 key",
+			token: func(t *testing.T) string { return sign(t, "ES384", "p256", i.p256, validClaims(nil)) },
+		},
+		{
+			name: "ES256 signature in ASN.1",
+			token: func(t *testing.T) string {
+				token := sign(t, "ES256", "p256", i.p256, validClaims(nil))
+				input := token[:strings.LastIndex(token, ".")]
+				h := crypto.SHA256.New()
+				h.Write([]byte(input))
+				signature, err := ecdsa.SignASN1(rand.Reader, i.p256, h.Sum(nil))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+			},
+		},
+		{
+			name: "ES256 signature one byte short",
+			token: func(t *testing.T) string {
+				parts := strings.Split(sign(t, "ES256", "p256", i.p256, validClaims(nil)), ".")
+				signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+				parts[2] = base64.RawURLEncoding.EncodeToString(signature[1:])
+				return strings.Join(parts, ".")
+			},
+		},
+		{
+			name: "tampered claims",
+			token: func(t *testing.T) string {
+				parts := strings.Split(sign(t, "RS256", "rsa", i.rsa, validClaims(nil)), ".")
+				parts[1] = segment(t, validClaims(map[string]any{"sub": "mallory"}))
+				return strings.Join(parts, ".")
+			},
+		},
+		{
+			name:  "unknown key",
+			token: func(t *testing.T) string { return sign(t, "RS256", "rotated", i.rsa, validClaims(nil)) },
+		},
+		{
+			name:  "malformed",
+			token: func(t *testing.T) string { return "header.claims" },
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T) string {
+				return sign(t, "RS256", "rsa", i.rsa, validClaims(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()}))
+			},
+		},
+		{
+			name: "expired within leeway",
+			token: func(t *testing.T) string {
+				return sign(t, "RS256", "rsa", i.rsa, validClaims(map[string]any{"exp": now.Add(-30 * time.Second).Unix()}))
+			},
+			valid: true,
+		},
+		{
+			name: "without expiry",
+			token: func(t *testing.T) string {
+				return sign(t, "RS256", "rsa", i.rsa, validClaims(map[string]any{"exp": nil}))
+			},
+		},
+		{
+			name: "not valid yet",
+			token: func(t *testing.T) string {
+				return sign(t, "RS256", "rsa", i.rsa, validClaims(map[string]any{"nbf": now.Add(2 * time.Minute).Unix()}))
+			},
+		},
+		{
+			name: "other issuer",
+			token: func(t *testing.T) string {
+				return sign(t, "RS256", "rsa", i.rsa, validClaims(map[string]any{"iss": "https://other.example"}))
+			},
+		},
+		{
+			name: "other audience",
+			token: func(t *testing.T) string {
+				return sign(t, "RS256", "rsa", i.rsa, validClaims(map[string]any{"aud": "other"}))
+			},
+		},
+		{
+			name: "without issuer",
+			token: func(t *testing.T) string {
+				return sign(t, "RS256", "rsa", i.rsa, validClaims(map[string]any{"iss": nil}))
+			},
+		},
+		{
+			name: "without audience",
+			token: func(t *testing.T) string {
+				return sign(t, "RS256", "rsa", i.rsa, validClaims(map[string]any{"aud": nil}))
+			},
+		},
+		{
+			name:      "no issuer configured",
+			token:     func(t *testing.T) string { return sign(t, "RS256", "rsa", i.rsa, validClaims(nil)) },
+			configure: func(conf *config.OIDCConfig) { conf.Issuer = "" },
+		},
+		{
+			name:      "no audience configured",
+			token:     func(t *testing.T) string { return sign(t, "RS256", "rsa", i.rsa, validClaims(nil)) },
+			configure: func(conf *config.OIDCConfig) { conf.Audience = "" },
+		},
+		{
+			name: "audience among several",
+			token: func(t *testing.T) string {
+				return sign(t, "RS256", "rsa", i.rsa, validClaims(map[string]any{"aud": []string{"other", "ing-portal"}}))
+			},
+			valid: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := i.conf()
+			if test.configure != nil {
+				test.configure(&conf)
+			}
+
+			claims, err := verifyJWT(context.Background(), conf, test.token(t))
+			if test.valid && err != nil {
+				t.Fatalf("expected a valid token, got %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatalf("expected an invalid token, got claims %v", claims)
+			}
+			if test.valid && claims.string("sub") != "alice" {
+				t.Fatalf("expected subject alice, got %q", claims.string("sub"))
+			}
+		})
+	}
+}
+
+// relabel changes the algorithm in the header of a token, keeping its
+// signature.
+func relabel(t *testing.T, token string, alg string) string {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	var header map[string]string
+	if err := decodeSegment(parts[0], &header); err != nil {
+		t.Fatal(err)
+	}
+	header["alg"] = alg
+	parts[0] = segment(t, header)
+	return strings.Join(parts, ".")
+}
+
+func TestFetchKeysSkipsUnusableKeys(t *testing.T) {
+	i := newIssuer(t)
+	size := 32
+
+	write := func(t *testing.T, keys ...jwk) string {
+		t.Helper()
+
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		data, err := json.Marshal(map[string][]jwk{"keys": keys})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return "file://" + path
+	}
+
+	unusable := []jwk{
+		{Kid: "ed25519", Kty: "OKP", Use: "sig", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(make([]byte, size))},
+		{Kid: "secp256k1", Kty: "EC", Use: "sig", Crv: "secp256k1", X: "AA", Y: "AA"},
+	}
+	usable := jwk{
+		Kid: "p256",
+		Kty: "EC",
+		Use: "sig",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(i.p256.X.FillBytes(make([]byte, size))),
+		Y:   base64.RawURLEncoding.EncodeToString(i.p256.Y.FillBytes(make([]byte, size))),
+	}
+
+	keys, err := fetchKeys(context.Background(), write(t, append(unusable, usable)...))
+	if err != nil {
+		t.Fatalf("expected the usable key, got %v", err)
+	}
+	if _, ok := keys["p256"]; !ok || len(keys) != 1 {
+		t.Fatalf("expected only the P-256 key, got %v", keys)
+	}
+
+	if _, err := fetchKeys(context.Background(), write(t, unusable...)); err == nil {
+		t.Fatal("expected an error without any usable key")
+	}
+}
+
+func TestKeySetKeepsKeysWhenRefreshFails(t *testing.T) {
+	i := newIssuer(t)
+	set := keySetFor(i.conf().JwksUrl)
+	ctx := context.Background()
+
+	if _, err := set.key(ctx, "rsa"); err != nil {
+		t.Fatalf("expected the key to be fetched, got %v", err)
+	}
+
+	// Make the keys stale and the issuer unreachable.
+	if err := os.Remove(i.path); err != nil {
+		t.Fatal(err)
+	}
+	set.mu.Lock()
+	set.fetched = time.Now().Add(-2 * jwksRefresh)
+	set.nextFetch = time.Time{}
+	set.mu.Unlock()
+
+	if _, err := set.key(ctx, "rsa"); err != nil {
+		t.Fatalf("expected the stale key while refreshing, got %v", err)
+	}
+	awaitFetch(set)
+
+	if _, err := set.key(ctx, "rsa"); err != nil {
+		t.Fatalf("expected the stale key after a failed refresh, got %v", err)
+	}
+
+	// A failed fetch is not retried right away, not even for unknown keys.
+	set.mu.Lock()
+	failures, backedOff := set.failures, set.nextFetch.After(time.Now())
+	set.mu.Unlock()
+	if failures != 1 || !backedOff {
+		t.Fatalf("expected one failure and a backoff, got %d failure(s), backed off %v", failures, backedOff)
+	}
+
+	_, err := set.key(ctx, "rotated")
+	if err == nil || errors.Is(err, errUnknownKey) {
+		t.Fatalf("expected the fetch error for an unknown key, got %v", err)
+	}
+	set.mu.Lock()
+	fetching := set.fetching != nil
+	set.mu.Unlock()
+	if fetching {
+		t.Fatal("expected no fetch while backing off")
+	}
+}
+
+func awaitFetch(set *keySet) {
+	set.mu.Lock()
+	done := set.fetching
+	set.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"ai-test/config"
+	"cmp"
+	"slices"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// bearerTokens authenticates OIDC access or ID tokens issued by the
+// configured identity provider.
+type bearerTokens struct{}
+
+func (bearerTokens) Authenticate(c fiber.Ctx, conf config.AuthConfig) (*User, error) {
+	if conf.OIDC.JwksUrl == "" {
+		return nil, nil
+	}
+
+	token := bearer(c, "Bearer")
+	if token == "" {
+		return nil, nil
+	}
+
+	claims, err := verifyJWT(c.Context(), conf.OIDC, token)
+	if err != nil {
+		return nil, err
+	}
+
+	id := claims.string(cmp.Or(conf.OIDC.UserClaim, "sub"))
+	if id == "" {
+		return nil, errInvalidCredentials
+	}
+
+	return &User{
+		Id:     id,
+		Name:   cmp.Or(claims.string("email"), claims.string("name")),
+		Method: MethodJWT,
+		Admin:  conf.OIDC.AdminRole != "" && slices.Contains(claims.strings(conf.OIDC.RolesClaim), conf.OIDC.AdminRole),
+	}, nil
+}
@@ -0,0 +1,54 @@
+package buildinfo
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// Commit and BuildTime are set when building the server:
+//
+//	go build -ldflags "-X ai-test/buildinfo.Commit=$(git rev-parse HEAD) -X ai-test/buildinfo.BuildTime=$(date -u +%FT%TZ)"
+//
+// Left empty, they fall back to the version control information Go records
+// when building inside the repository, the build time then being the time of
+// the commit.
+var (
+	Commit    string
+	BuildTime string
+)
+
+type Info struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	Modified  bool   `json:"modified"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the build information of the running binary, unknown parts
+// being empty.
+var Get = sync.OnceValue(func() Info {
+	info := Info{Commit: Commit, BuildTime: BuildTime}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.GoVersion = build.GoVersion
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+
+	return info
+})
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"ai-test/config"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+const (
+	MemoryBackend = "memory"
+	DiskBackend   = "disk"
+)
+
+// sweepInterval bounds how often a store drops its expired entries. Sweeps
+// piggyback on writes, so an idle store costs nothing.
+const sweepInterval = time.Minute
+
+// Store keeps generated payloads for a limited time. Implementations must be
+// safe for concurrent use.
+type Store interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+}
+
+// New builds the store selected by the cache configuration. A disabled cache
+// returns a store that never hits.
+func New(conf config.CacheConfig) Store {
+	if !conf.Enabled {
+		return noopStore{}
+	}
+
+	switch conf.Backend {
+	case DiskBackend:
+		return NewDiskStore(conf.Directory, conf.TTL)
+	default:
+		return NewMemoryStore(conf.TTL)
+	}
+}
+
+// Key hashes the given parts into a stable cache key. Parts are JSON encoded,
+// so callers should normalize them before passing them in.
+func Key(parts ...any) string {
+	hash := sha256.New()
+	encoder := json.NewEncoder(hash)
+
+	for _, part := range parts {
+		_ = encoder.Encode(part)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type entry struct {
+	Value   []byte    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+func (e entry) expired() bool {
+	return !e.Expires.IsZero() && time.Now().After(e.Expires)
+}
+
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+type noopStore struct{}
+
+func (noopStore) Get(string) ([]byte, bool) { return nil, false }
+func (noopStore) Set(string, []byte)        {}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"ai-test/util"
+	"ai-test/util/level"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskStore keeps one JSON file per key, which lets cached generations
+// survive restarts and be shared between processes on the same host.
+type DiskStore struct {
+	dir string
+	ttl time.Duration
+
+	mu       sync.Mutex
+	sweeping bool
+	swept    time.Time
+}
+
+func NewDiskStore(dir string, ttl time.Duration) *DiskStore {
+	err := os.MkdirAll(dir, 0o755)
+	util.HandleError("Could not create cache directory: %v", err, level.ERROR)
+
+	return &DiskStore{
+		dir: dir,
+		ttl: ttl,
+	}
+}
+
+func (s *DiskStore) Get(key string) ([]byte, bool) {
+	path := s.path(key)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		util.HandleError("Could not read cache entry: %v", err, level.WARN)
+		_ = os.Remove(path)
+		return nil, false
+	}
+
+	if e.expired() {
+		_ = os.Remove(path)
+		return nil, false
+	}
+
+	return e.Value, true
+}
+
+func (s *DiskStore) Set(key string, value []byte) {
+	data, err := json.Marshal(entry{
+		Value:   value,
+		Expires: expiry(s.ttl),
+	})
+	if err != nil {
+		util.HandleError("Could not encode cache entry: %v", err, level.WARN)
+		return
+	}
+
+	// Write to a temporary file first so readers never see a partial entry.
+	tmp, err := os.CreateTemp(s.dir, key+".*.tmp")
+	if err != nil {
+		util.HandleError("Could not write cache entry: %v", err, level.WARN)
+		return
+	}
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path(key))
+	}
+
+	if err != nil {
+		util.HandleError("Could not write cache entry: %v", err, level.WARN)
+		_ = os.Remove(tmp.Name())
+	}
+
+	s.mu.Lock()
+	due := !s.sweeping && time.Since(s.swept) >= sweepInterval
+	s.sweeping = s.sweeping || due
+	s.mu.Unlock()
+
+	if due {
+		go s.sweep()
+	}
+}
+
+// sweep removes the entries that expired without being read again, and the
+// temporary files of writes that never completed.
+func (s *DiskStore) sweep() {
+	defer func() {
+		s.mu.Lock()
+		s.sweeping = false
+		s.swept = time.Now()
+		s.mu.Unlock()
+	}()
+
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		util.HandleError("Could not sweep cache directory: %v", err, level.WARN)
+		return
+	}
+
+	for _, file := range files {
+		path := filepath.Join(s.dir, file.Name())
+
+		switch {
+		case strings.HasSuffix(file.Name(), ".tmp"):
+			if info, err := file.Info(); err == nil && time.Since(info.ModTime()) >= sweepInterval {
+				_ = os.Remove(path)
+			}
+		case strings.HasSuffix(file.Name(), ".json"):
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+
+			var e entry
+			if err := json.Unmarshal(data, &e); err != nil || e.expired() {
+				_ = os.Remove(path)
+			}
+		}
+	}
+}
+
+func (s *DiskStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
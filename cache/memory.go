@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type MemoryStore struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[string]entry
+	swept   time.Time
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:     ttl,
+		entries: make(map[string]entry),
+	}
+}
+
+func (s *MemoryStore) Get(key string) ([]byte, bool) {
+	s.mu.RLock()
+	e, ok := s.entries[key]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	if e.expired() {
+		s.mu.Lock()
+		delete(s.entries, key)
+		s.mu.Unlock()
+		return nil, false
+	}
+
+	return e.Value, true
+}
+
+func (s *MemoryStore) Set(key string, value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = entry{
+		Value:   value,
+		Expires: expiry(s.ttl),
+	}
+
+	if time.Since(s.swept) >= sweepInterval {
+		s.sweep()
+	}
+}
+
+// sweep drops the entries that expired without being read again, s.mu must
+// be held.
+func (s *MemoryStore) sweep() {
+	for key, e := range s.entries {
+		if e.expired() {
+			delete(s.entries, key)
+		}
+	}
+	s.swept = time.Now()
+}
@@ -1,25 +1,124 @@
 package config
 
 import (
+	"ai-test/health"
 	"ai-test/logger"
 	"ai-test/util"
 	"ai-test/util/level"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
 
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 var log = logger.NewLogger()
 
-var C = &Config{}
+var current atomic.Pointer[Config]
 
+func init() {
+	health.Register(health.Func("config", func(context.Context) error {
+		if current.Load() == nil {
+			return errors.New("configuration not loaded")
+		}
+		return nil
+	}))
+}
+
+// Get returns the active configuration. The returned value must be treated
+// as read-only, a reload swaps in a new Config instead of mutating it.
+func Get() *Config {
+	return current.Load()
+}
+
+// EnvPrefix prefixes every environment variable override, e.g.
+// ING_PORTAL_VERTEX_MODEL_NAME overrides vertex.model.name.
+const EnvPrefix = "ING_PORTAL"
+
+// ReadConfigFile loads the configuration in layers, each overriding the
+// previous one: defaults, the config file, ING_PORTAL_* environment variables
+// and finally command-line flags.
 func ReadConfigFile() {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
+	setDefaults()
+
+	flags := parseFlags()
+	for key, flag := range flagKeys {
+		err := viper.BindPFlag(key, flags.Lookup(flag))
+		util.HandleError("Could not bind flag: %v", err, level.FATAL)
+	}
+
+	viper.SetEnvPrefix(EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+	bindEnvs()
+
+	// Cloud Run tells the container which port to listen on through PORT.
+	err := viper.BindEnv("server.port", EnvPrefix+"_SERVER_PORT", "PORT")
+	util.HandleError("Could not bind environment variable: %v", err, level.FATAL)
+
+	fileLoaded := readFile(flags)
+
+	conf, err := decode()
+	if check, _ := flags.GetBool(checkConfigFlag); check {
+		reportCheck(err)
+	}
+	util.HandleError("Invalid configuration, %v", err, level.FATAL)
+
+	activate(conf)
+
+	if fileLoaded {
+		watch()
+	}
+}
+
+func readFile(flags *pflag.FlagSet) bool {
+	path, _ := flags.GetString(configFlag)
+
+	if path != "" {
+		viper.SetConfigFile(path)
+	} else {
+		viper.SetConfigName("config")
+		viper.SetConfigType("yaml")
+		viper.AddConfigPath(".")
+	}
 
 	err := viper.ReadInConfig()
-	util.HandleError("Could not read config file: %v", err, level.FATAL)
 
-	err = viper.Unmarshal(C)
+	// Without an explicit --config the file is optional, the whole
+	// configuration can come from the environment.
+	var notFound viper.ConfigFileNotFoundError
+	if path == "" && errors.As(err, &notFound) {
+		log.Warn("No config.yaml found, using defaults and environment variables")
+		return false
+	}
+
 	util.HandleError("Could not read config file: %v", err, level.FATAL)
+	return true
+}
+
+// bindEnvs registers every key of the config struct with viper, so that
+// environment variables are picked up by Unmarshal even when the key is
+// missing from both the config file and the defaults.
+func bindEnvs() {
+	for _, key := range keys(reflect.TypeOf(Config{}), "") {
+		err := viper.BindEnv(key)
+		util.HandleError("Could not bind environment variable: %v", err, level.FATAL)
+	}
+}
+
+// reportCheck prints the outcome of --check-config and exits with a status
+// reflecting it.
+func reportCheck(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration is invalid, %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Configuration is valid (%s)\n", viper.ConfigFileUsed())
+	os.Exit(0)
 }
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+)
+
+// decode unmarshals the merged settings and validates the result. Keys that
+// match no Config field are reported as problems too, since mapstructure
+// would otherwise leave the intended field at its zero value.
+func decode() (*Config, error) {
+	conf := &Config{}
+	metadata := &mapstructure.Metadata{}
+
+	err := viper.Unmarshal(conf, func(dc *mapstructure.DecoderConfig) {
+		dc.Metadata = metadata
+	})
+
+	var problems []string
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	known := keys(reflect.TypeOf(Config{}), "")
+	for _, key := range metadata.Unused {
+		problems = append(problems, unknownKey(key, known))
+	}
+
+	if verr, ok := conf.Validate().(*ValidationError); ok {
+		problems = append(problems, verr.Problems...)
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return conf, nil
+}
+
+// keys lists the dotted keys of every leaf field of the config struct.
+func keys(t reflect.Type, prefix string) []string {
+	var result []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := prefix + name
+		if field.Type.Kind() == reflect.Struct && field.Type.PkgPath() == t.PkgPath() {
+			result = append(result, keys(field.Type, key+".")...)
+			continue
+		}
+
+		result = append(result, key)
+	}
+
+	return result
+}
+
+func unknownKey(key string, known []string) string {
+	best, bestDistance := "", 4
+	for _, candidate := range known {
+		if d := distance(strings.ToLower(key), strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+
+	if best == "" {
+		return "unknown key " + key
+	}
+	return "unknown key " + key + " (did you mean " + best + "?)"
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous = current
+	}
+
+	return previous[len(b)]
+}
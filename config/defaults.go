@@ -0,0 +1,73 @@
+package config
+
+import "github.com/spf13/viper"
+
+func setDefaults() {
+	viper.SetDefault("provider", VertexProvider)
+
+	viper.SetDefault("server.port", 8080)
+	viper.SetDefault("server.prefork", true)
+	viper.SetDefault("server.shutdownTimeout", "30s")
+	viper.SetDefault("server.staticDir", "./frontend/dist")
+
+	viper.SetDefault("vertex.timeouts.generation", "5m")
+	viper.SetDefault("vertex.timeouts.formatting", "3m")
+	viper.SetDefault("vertex.timeouts.chat", "2m")
+	viper.SetDefault("vertex.retry.maxAttempts", 3)
+	viper.SetDefault("vertex.retry.initialBackoff", "500ms")
+	viper.SetDefault("vertex.retry.maxBackoff", "8s")
+	viper.SetDefault("vertex.retry.multiplier", 2.0)
+	viper.SetDefault("vertex.retry.jitter", 0.2)
+	viper.SetDefault("vertex.retry.retryableStatuses", []string{"429", "5xx", "network"})
+	viper.SetDefault("vertex.circuitBreaker.failureThreshold", 5)
+	viper.SetDefault("vertex.circuitBreaker.openTimeout", "30s")
+
+	viper.SetDefault("prompts.directory", "./prompts")
+	viper.SetDefault("prompts.default", "v3")
+
+	viper.SetDefault("cache.enabled", true)
+	viper.SetDefault("cache.backend", "memory")
+	viper.SetDefault("cache.ttl", "24h")
+	viper.SetDefault("cache.directory", "./.cache/generations")
+
+	viper.SetDefault("secrets.redact", false)
+	viper.SetDefault("secrets.allowed", []string{"e77d776b-90af-4684-bebc-521e5b2614dd"})
+
+	viper.SetDefault("files.maxFileSize", 256*1024)
+	viper.SetDefault("files.maxTotalSize", 2*1024*1024)
+
+	viper.SetDefault("formatting.enabled", true)
+	viper.SetDefault("formatting.timeout", "10s")
+
+	viper.SetDefault("syntax.enabled", true)
+	viper.SetDefault("syntax.timeout", "10s")
+
+	viper.SetDefault("coverage.directory", "./data")
+	viper.SetDefault("coverage.reprompt", false)
+
+	viper.SetDefault("execution.enabled", false)
+	viper.SetDefault("execution.timeout", "2m")
+
+	viper.SetDefault("auth.enabled", false)
+	viper.SetDefault("auth.oidc.userClaim", "sub")
+	viper.SetDefault("auth.oidc.rolesClaim", "roles")
+	viper.SetDefault("auth.oidc.adminRole", "admin")
+
+	viper.SetDefault("store.backend", "file")
+	viper.SetDefault("store.directory", "./.state")
+	viper.SetDefault("store.url", "redis://localhost:6379/0")
+	viper.SetDefault("store.retention", "24h")
+
+	viper.SetDefault("quota.enabled", true)
+	viper.SetDefault("quota.user.requestsPerMinute", 10)
+	viper.SetDefault("quota.user.concurrentGenerations", 2)
+	viper.SetDefault("quota.user.dailyTokens", 2_000_000)
+	viper.SetDefault("quota.global.requestsPerMinute", 120)
+	viper.SetDefault("quota.global.concurrentGenerations", 20)
+	viper.SetDefault("quota.global.dailyTokens", 50_000_000)
+
+	viper.SetDefault("archive.certificates.enabled", true)
+	viper.SetDefault("archive.certificates.directory", "./certs")
+	viper.SetDefault("archive.certificates.certificate", "example_client_tls.cer")
+	viper.SetDefault("archive.certificates.key", "example_client_tls.key")
+}
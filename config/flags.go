@@ -0,0 +1,34 @@
+package config
+
+import (
+	"os"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	configFlag      = "config"
+	checkConfigFlag = "check-config"
+)
+
+// flagKeys maps config keys to the command-line flags overriding them.
+var flagKeys = map[string]string{
+	"server.port":      "port",
+	"server.prefork":   "prefork",
+	"server.staticDir": "static-dir",
+}
+
+func parseFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
+
+	flags.String(configFlag, "", "path to the config file (default ./config.yaml)")
+	flags.Bool(checkConfigFlag, false, "validate the configuration and exit")
+	flags.Int("port", 8080, "port the server listens on")
+	flags.Bool("prefork", true, "spawn one server process per CPU")
+	flags.String("static-dir", "./frontend/dist", "directory of the built frontend")
+
+	// Parse never returns an error with ExitOnError.
+	_ = flags.Parse(os.Args[1:])
+
+	return flags
+}
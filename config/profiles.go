@@ -0,0 +1,55 @@
+package config
+
+import (
+	"slices"
+	"strings"
+)
+
+// ModelSectionProfile is the name under which the vertex.model section is
+// available as a profile.
+const ModelSectionProfile = "default"
+
+// ModelProfiles returns every selectable model profile by name. The
+// vertex.model section is always included as the "default" profile unless a
+// named profile overrides it.
+func (c VertexAIConfig) ModelProfiles() map[string]AIModelConfig {
+	profiles := map[string]AIModelConfig{
+		ModelSectionProfile: c.Model,
+	}
+
+	for name, profile := range c.Profiles {
+		profiles[strings.ToLower(name)] = profile
+	}
+
+	return profiles
+}
+
+// ProfileNames returns the profile names in a stable order.
+func (c VertexAIConfig) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles)+1)
+	for name := range c.ModelProfiles() {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+// DefaultProfileName returns the profile used when a request does not select one.
+func (c VertexAIConfig) DefaultProfileName() string {
+	if c.DefaultProfile == "" {
+		return ModelSectionProfile
+	}
+	return strings.ToLower(c.DefaultProfile)
+}
+
+// Profile resolves a profile by name, an empty name selects the default one.
+func (c VertexAIConfig) Profile(name string) (string, AIModelConfig, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = c.DefaultProfileName()
+	}
+
+	profile, ok := c.ModelProfiles()[name]
+	return name, profile, ok
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Version identifies the active configuration. Hash is derived from the
+// configuration content, so prefork children that loaded the same file report
+// the same hash even though their revision counters are independent.
+type Version struct {
+	Hash     string    `json:"hash"`
+	Revision int64     `json:"revision"`
+	LoadedAt time.Time `json:"loadedAt"`
+}
+
+var (
+	version   atomic.Pointer[Version]
+	revision  atomic.Int64
+	reloadMu  sync.Mutex
+	listeners []func(*Config)
+)
+
+// ActiveVersion returns the version of the configuration returned by Get.
+func ActiveVersion() Version {
+	if v := version.Load(); v != nil {
+		return *v
+	}
+	return Version{}
+}
+
+// OnChange registers fn to be called with the new configuration after every
+// successful reload.
+func OnChange(fn func(*Config)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	listeners = append(listeners, fn)
+}
+
+func activate(conf *Config) {
+	encoded, _ := json.Marshal(conf)
+	hash := sha256.Sum256(encoded)
+
+	current.Store(conf)
+	version.Store(&Version{
+		Hash:     hex.EncodeToString(hash[:])[:12],
+		Revision: revision.Add(1),
+		LoadedAt: time.Now(),
+	})
+}
+
+func watch() {
+	viper.OnConfigChange(func(event fsnotify.Event) {
+		reload(event.Name)
+	})
+	viper.WatchConfig()
+}
+
+// reload validates the changed file and swaps it in. An invalid file is
+// rejected as a whole and the previous configuration stays active.
+func reload(file string) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	next, err := decode()
+	if err != nil {
+		log.Warnf("Ignoring change to %s, %v", file, err)
+		return
+	}
+
+	previous := Get()
+	if previous.Server != next.Server {
+		log.Warn("Changes to the server section only take effect after a restart")
+	}
+
+	activate(next)
+	log.Infof("Reloaded configuration from %s (version %s)", file, ActiveVersion().Hash)
+
+	for _, listener := range listeners {
+		listener(next)
+	}
+}
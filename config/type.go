@@ -1,27 +1,204 @@
 package config
 
+import "time"
+
 type Config struct {
-	Vertex VertexAIConfig `yaml:"vertex"`
+	Provider   string           `mapstructure:"provider" json:"provider"`
+	Server     ServerConfig     `mapstructure:"server" json:"server"`
+	Vertex     VertexAIConfig   `mapstructure:"vertex" json:"vertex"`
+	Prompts    PromptsConfig    `mapstructure:"prompts" json:"prompts"`
+	Cache      CacheConfig      `mapstructure:"cache" json:"cache"`
+	Archive    ArchiveConfig    `mapstructure:"archive" json:"archive"`
+	Secrets    SecretsConfig    `mapstructure:"secrets" json:"secrets"`
+	Files      FilesConfig      `mapstructure:"files" json:"files"`
+	Formatting FormattingConfig `mapstructure:"formatting" json:"formatting"`
+	Syntax     SyntaxConfig     `mapstructure:"syntax" json:"syntax"`
+	Coverage   CoverageConfig   `mapstructure:"coverage" json:"coverage"`
+	Execution  ExecutionConfig  `mapstructure:"execution" json:"execution"`
+	Auth       AuthConfig       `mapstructure:"auth" json:"auth"`
+	Store      StoreConfig      `mapstructure:"store" json:"store"`
+	Quota      QuotaConfig      `mapstructure:"quota" json:"quota"`
+}
+
+// ServerConfig.ShutdownTimeout bounds how long running jobs are waited for
+// once the server is asked to stop.
+type ServerConfig struct {
+	Port            int           `mapstructure:"port" json:"port"`
+	Prefork         bool          `mapstructure:"prefork" json:"prefork"`
+	StaticDir       string        `mapstructure:"staticDir" json:"staticDir"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdownTimeout" json:"shutdownTimeout"`
 }
 
 type VertexAIConfig struct {
-	Project   ProjectConfig   `yaml:"project"`
-	DataStore DataStoreConfig `yaml:"dataStore"`
-	Model     AIModelConfig   `yaml:"model"`
+	Project        ProjectConfig            `mapstructure:"project" json:"project"`
+	DataStore      DataStoreConfig          `mapstructure:"dataStore" json:"dataStore"`
+	Model          AIModelConfig            `mapstructure:"model" json:"model"`
+	Profiles       map[string]AIModelConfig `mapstructure:"profiles" json:"profiles"`
+	DefaultProfile string                   `mapstructure:"defaultProfile" json:"defaultProfile"`
+	Timeouts       TimeoutConfig            `mapstructure:"timeouts" json:"timeouts"`
+	Retry          RetryConfig              `mapstructure:"retry" json:"retry"`
+	CircuitBreaker CircuitBreakerConfig     `mapstructure:"circuitBreaker" json:"circuitBreaker"`
 }
 
 type ProjectConfig struct {
-	Id string `yaml:"id"`
+	Id string `mapstructure:"id" json:"id"`
 }
 
 type DataStoreConfig struct {
-	Id       string `yaml:"id"`
-	Location string `yaml:"location"`
+	Id       string `mapstructure:"id" json:"id"`
+	Location string `mapstructure:"location" json:"location"`
 }
 
 type AIModelConfig struct {
-	Name            string  `yaml:"name"`
-	Location        string  `yaml:"location"`
-	Temperature     float32 `yaml:"temperature"`
-	MaxOutputTokens int32   `yaml:"maxOutputTokens"`
+	Name            string  `mapstructure:"name" json:"name"`
+	Location        string  `mapstructure:"location" json:"location"`
+	Temperature     float32 `mapstructure:"temperature" json:"temperature"`
+	MaxOutputTokens int32   `mapstructure:"maxOutputTokens" json:"maxOutputTokens"`
+	Prompt          string  `mapstructure:"prompt" json:"prompt,omitempty"`
+}
+
+// TimeoutConfig bounds each stage of the generation pipeline. Zero disables
+// the timeout of that stage.
+type TimeoutConfig struct {
+	Generation time.Duration `mapstructure:"generation" json:"generation"`
+	Formatting time.Duration `mapstructure:"formatting" json:"formatting"`
+	Chat       time.Duration `mapstructure:"chat" json:"chat"`
+}
+
+type RetryConfig struct {
+	MaxAttempts       int           `mapstructure:"maxAttempts" json:"maxAttempts"`
+	InitialBackoff    time.Duration `mapstructure:"initialBackoff" json:"initialBackoff"`
+	MaxBackoff        time.Duration `mapstructure:"maxBackoff" json:"maxBackoff"`
+	Multiplier        float64       `mapstructure:"multiplier" json:"multiplier"`
+	Jitter            float64       `mapstructure:"jitter" json:"jitter"`
+	RetryableStatuses []string      `mapstructure:"retryableStatuses" json:"retryableStatuses"`
+}
+
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `mapstructure:"failureThreshold" json:"failureThreshold"`
+	OpenTimeout      time.Duration `mapstructure:"openTimeout" json:"openTimeout"`
+}
+
+// PromptsConfig points to the versioned system prompt templates. Default is
+// used unless the request or the selected profile asks for another version.
+type PromptsConfig struct {
+	Directory string `mapstructure:"directory" json:"directory"`
+	Default   string `mapstructure:"default" json:"default"`
+}
+
+type CacheConfig struct {
+	Enabled   bool          `mapstructure:"enabled" json:"enabled"`
+	Backend   string        `mapstructure:"backend" json:"backend"`
+	TTL       time.Duration `mapstructure:"ttl" json:"ttl"`
+	Directory string        `mapstructure:"directory" json:"directory"`
+}
+
+// ArchiveConfig controls what is added to the downloaded project next to the
+// generated files.
+type ArchiveConfig struct {
+	Certificates CertificatesConfig `mapstructure:"certificates" json:"certificates"`
+}
+
+// CertificatesConfig points to the sandbox example certificates bundled into
+// archives of sandbox clients. They are read from the server, never taken
+// from the model output.
+type CertificatesConfig struct {
+	Enabled     bool   `mapstructure:"enabled" json:"enabled"`
+	Directory   string `mapstructure:"directory" json:"directory"`
+	Certificate string `mapstructure:"certificate" json:"certificate"`
+	Key         string `mapstructure:"key" json:"key"`
+}
+
+// SecretsConfig controls the scan of generated files for credentials. Allowed
+// values, like the public sandbox client id, are never reported.
+type SecretsConfig struct {
+	Redact  bool     `mapstructure:"redact" json:"redact"`
+	Allowed []string `mapstructure:"allowed" json:"allowed"`
+}
+
+// FilesConfig limits the size of generated files in bytes, zero disables a
+// limit.
+type FilesConfig struct {
+	MaxFileSize  int `mapstructure:"maxFileSize" json:"maxFileSize"`
+	MaxTotalSize int `mapstructure:"maxTotalSize" json:"maxTotalSize"`
+}
+
+// FormattingConfig controls the formatting of generated code, Timeout bounds
+// each external formatter run.
+type FormattingConfig struct {
+	Enabled bool          `mapstructure:"enabled" json:"enabled"`
+	Timeout time.Duration `mapstructure:"timeout" json:"timeout"`
+}
+
+// SyntaxConfig controls the syntax check of generated files, Timeout bounds
+// each external checker run.
+type SyntaxConfig struct {
+	Enabled bool          `mapstructure:"enabled" json:"enabled"`
+	Timeout time.Duration `mapstructure:"timeout" json:"timeout"`
+}
+
+// CoverageConfig points to the API specifications generated clients are
+// checked against. With Reprompt the model is always asked once more for
+// the endpoints it left out.
+type CoverageConfig struct {
+	Directory string `mapstructure:"directory" json:"directory"`
+	Reprompt  bool   `mapstructure:"reprompt" json:"reprompt"`
+}
+
+// ExecutionConfig controls running generated Go clients against a mock of
+// the sandbox. With Enabled the generations of administrators are run, each
+// client isolated in its own namespaces, which the host must allow
+// unprivileged users to create.
+type ExecutionConfig struct {
+	Enabled bool          `mapstructure:"enabled" json:"enabled"`
+	Timeout time.Duration `mapstructure:"timeout" json:"timeout"`
+}
+
+// AuthConfig protects the API. Callers authenticate with one of the static
+// API keys or with a bearer token of the OIDC provider.
+type AuthConfig struct {
+	Enabled bool           `mapstructure:"enabled" json:"enabled"`
+	ApiKeys []ApiKeyConfig `mapstructure:"apiKeys" json:"apiKeys"`
+	OIDC    OIDCConfig     `mapstructure:"oidc" json:"oidc"`
+}
+
+type ApiKeyConfig struct {
+	Name  string `mapstructure:"name" json:"name"`
+	Key   string `mapstructure:"key" json:"-"`
+	Admin bool   `mapstructure:"admin" json:"admin"`
+}
+
+// OIDCConfig validates bearer tokens against the keys at JwksUrl, which may
+// be a file:// URL for a local issuer. Tokens must be issued by Issuer for
+// Audience. Holders of AdminRole in RolesClaim are administrators.
+type OIDCConfig struct {
+	Issuer     string `mapstructure:"issuer" json:"issuer"`
+	Audience   string `mapstructure:"audience" json:"audience"`
+	JwksUrl    string `mapstructure:"jwksUrl" json:"jwksUrl"`
+	UserClaim  string `mapstructure:"userClaim" json:"userClaim"`
+	RolesClaim string `mapstructure:"rolesClaim" json:"rolesClaim"`
+	AdminRole  string `mapstructure:"adminRole" json:"adminRole"`
+}
+
+// StoreConfig selects where the state shared between prefork children is
+// kept. Retention bounds how long sessions and results outlive their last use.
+type StoreConfig struct {
+	Backend   string        `mapstructure:"backend" json:"backend"`
+	Directory string        `mapstructure:"directory" json:"directory"`
+	Url       string        `mapstructure:"url" json:"-"`
+	Retention time.Duration `mapstructure:"retention" json:"retention"`
+}
+
+// QuotaConfig limits the use of the model, per user and for the whole
+// server. A zero limit is no limit.
+type QuotaConfig struct {
+	Enabled bool        `mapstructure:"enabled" json:"enabled"`
+	User    QuotaLimits `mapstructure:"user" json:"user"`
+	Global  QuotaLimits `mapstructure:"global" json:"global"`
+}
+
+type QuotaLimits struct {
+	RequestsPerMinute     int64 `mapstructure:"requestsPerMinute" json:"requestsPerMinute"`
+	ConcurrentGenerations int64 `mapstructure:"concurrentGenerations" json:"concurrentGenerations"`
+	DailyTokens           int64 `mapstructure:"dailyTokens" json:"dailyTokens"`
 }
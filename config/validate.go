@@ -0,0 +1,274 @@
+package config
+
+import (
+	"ai-test/prompts"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const VertexProvider = "vertex"
+
+var (
+	SupportedProviders = []string{VertexProvider}
+
+	SupportedModels = []string{
+		"gemini-2.5-pro",
+		"gemini-2.5-flash",
+		"gemini-2.5-flash-lite",
+		"gemini-2.0-flash",
+		"gemini-2.0-flash-lite",
+	}
+
+	// ModelLocations are the Vertex AI regions serving the Gemini models.
+	ModelLocations = []string{
+		"global",
+		"us-central1", "us-east1", "us-east4", "us-east5", "us-south1", "us-west1", "us-west4",
+		"northamerica-northeast1", "southamerica-east1",
+		"europe-central2", "europe-north1", "europe-southwest1", "europe-west1", "europe-west2",
+		"europe-west3", "europe-west4", "europe-west6", "europe-west8", "europe-west9",
+		"asia-east1", "asia-east2", "asia-northeast1", "asia-northeast3", "asia-south1", "asia-southeast1",
+		"australia-southeast1", "me-central1", "me-west1",
+	}
+
+	// DataStoreLocations are the multi-regions Vertex AI Search data stores live in.
+	DataStoreLocations = []string{"global", "us", "eu"}
+
+	CacheBackends = []string{"memory", "disk"}
+
+	StoreBackends = []string{"memory", "file", "redis"}
+)
+
+// ValidationError lists every problem found in a configuration, so all of
+// them can be fixed in one go instead of one restart per mistake.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d problem(s) found:\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) required(key string, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add("%s is required", key)
+	}
+}
+
+func (v *validator) oneOf(key string, value string, allowed []string) {
+	if !slices.Contains(allowed, value) {
+		v.add("%s must be one of [%s], got %q", key, strings.Join(allowed, ", "), value)
+	}
+}
+
+func (v *validator) nonNegative(key string, value int64) {
+	if value < 0 {
+		v.add("%s must not be negative, got %d", key, value)
+	}
+}
+
+// Validate checks the configuration and returns a *ValidationError listing
+// every problem found, or nil.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.oneOf("provider", c.Provider, SupportedProviders)
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		v.add("server.port must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	v.required("server.staticDir", c.Server.StaticDir)
+	v.nonNegative("server.shutdownTimeout", int64(c.Server.ShutdownTimeout))
+
+	if c.Provider == VertexProvider {
+		c.Vertex.validate(v)
+	}
+
+	v.required("prompts.directory", c.Prompts.Directory)
+	v.required("prompts.default", c.Prompts.Default)
+	c.validatePrompts(v)
+
+	if c.Cache.Enabled {
+		v.oneOf("cache.backend", c.Cache.Backend, CacheBackends)
+		if c.Cache.Backend == "disk" {
+			v.required("cache.directory", c.Cache.Directory)
+		}
+		v.nonNegative("cache.ttl", int64(c.Cache.TTL))
+	}
+
+	v.nonNegative("files.maxFileSize", int64(c.Files.MaxFileSize))
+	v.nonNegative("files.maxTotalSize", int64(c.Files.MaxTotalSize))
+
+	v.nonNegative("formatting.timeout", int64(c.Formatting.Timeout))
+	v.nonNegative("syntax.timeout", int64(c.Syntax.Timeout))
+	v.nonNegative("execution.timeout", int64(c.Execution.Timeout))
+	if c.Execution.Enabled && !c.Auth.Enabled {
+		v.add("execution.enabled requires auth.enabled, only administrators get their clients run")
+	}
+
+	v.oneOf("store.backend", c.Store.Backend, StoreBackends)
+	switch c.Store.Backend {
+	case "file":
+		v.required("store.directory", c.Store.Directory)
+	case "redis":
+		if u, err := url.Parse(c.Store.Url); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+			v.add("store.url must be a redis:// or rediss:// URL")
+		}
+	}
+	v.nonNegative("store.retention", int64(c.Store.Retention))
+	if c.Server.Prefork && c.Store.Backend == "memory" {
+		v.add("store.backend memory is not shared between prefork children, use another backend or disable server.prefork")
+	}
+
+	c.Quota.User.validate(v, "quota.user")
+	c.Quota.Global.validate(v, "quota.global")
+
+	if c.Auth.Enabled {
+		c.Auth.validate(v)
+	}
+
+	if c.Archive.Certificates.Enabled {
+		v.required("archive.certificates.directory", c.Archive.Certificates.Directory)
+		v.required("archive.certificates.certificate", c.Archive.Certificates.Certificate)
+		v.required("archive.certificates.key", c.Archive.Certificates.Key)
+	}
+
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+func (c *VertexAIConfig) validate(v *validator) {
+	v.required("vertex.project.id", c.Project.Id)
+	v.required("vertex.dataStore.id", c.DataStore.Id)
+	v.oneOf("vertex.dataStore.location", c.DataStore.Location, DataStoreLocations)
+
+	c.Model.validate(v, "vertex.model")
+	for name, profile := range c.Profiles {
+		profile.validate(v, "vertex.profiles."+name)
+	}
+
+	if _, _, ok := c.Profile(""); !ok {
+		v.oneOf("vertex.defaultProfile", c.DefaultProfile, c.ProfileNames())
+	}
+
+	v.nonNegative("vertex.timeouts.generation", int64(c.Timeouts.Generation))
+	v.nonNegative("vertex.timeouts.formatting", int64(c.Timeouts.Formatting))
+	v.nonNegative("vertex.timeouts.chat", int64(c.Timeouts.Chat))
+
+	if c.Retry.MaxAttempts < 1 {
+		v.add("vertex.retry.maxAttempts must be at least 1, got %d", c.Retry.MaxAttempts)
+	}
+	if c.Retry.Jitter < 0 || c.Retry.Jitter > 1 {
+		v.add("vertex.retry.jitter must be between 0 and 1, got %v", c.Retry.Jitter)
+	}
+	for _, status := range c.Retry.RetryableStatuses {
+		if !validStatusClass(status) {
+			v.add("vertex.retry.retryableStatuses entry %q must be a status code, a class like 5xx or \"network\"", status)
+		}
+	}
+
+	v.nonNegative("vertex.circuitBreaker.failureThreshold", int64(c.CircuitBreaker.FailureThreshold))
+	v.nonNegative("vertex.circuitBreaker.openTimeout", int64(c.CircuitBreaker.OpenTimeout))
+}
+
+// validatePrompts loads the prompt library, so a missing directory or an
+// unknown version is reported at startup instead of on the first generation.
+func (c *Config) validatePrompts(v *validator) {
+	if strings.TrimSpace(c.Prompts.Directory) == "" {
+		return
+	}
+
+	library, err := prompts.Load(c.Prompts.Directory)
+	if err != nil {
+		v.add("prompts.directory could not be loaded: %v", err)
+		return
+	}
+
+	versions := library.Versions()
+	if c.Prompts.Default != "" {
+		v.oneOf("prompts.default", c.Prompts.Default, versions)
+	}
+
+	if c.Provider != VertexProvider {
+		return
+	}
+	if c.Vertex.Model.Prompt != "" {
+		v.oneOf("vertex.model.prompt", c.Vertex.Model.Prompt, versions)
+	}
+	for name, profile := range c.Vertex.Profiles {
+		if profile.Prompt != "" {
+			v.oneOf("vertex.profiles."+name+".prompt", profile.Prompt, versions)
+		}
+	}
+}
+
+func (c *AuthConfig) validate(v *validator) {
+	if len(c.ApiKeys) == 0 && c.OIDC.JwksUrl == "" {
+		v.add("auth requires auth.apiKeys or auth.oidc.jwksUrl when enabled")
+	}
+
+	for i, key := range c.ApiKeys {
+		v.required(fmt.Sprintf("auth.apiKeys[%d].name", i), key.Name)
+		if len(key.Key) < 16 {
+			v.add("auth.apiKeys[%d].key must be at least 16 characters", i)
+		}
+	}
+
+	if c.OIDC.JwksUrl != "" {
+		if u, err := url.Parse(c.OIDC.JwksUrl); err != nil || !slices.Contains([]string{"http", "https", "file"}, u.Scheme) {
+			v.add("auth.oidc.jwksUrl must be an http(s):// or file:// URL, got %q", c.OIDC.JwksUrl)
+		}
+		// Without both, a token the issuer made for any other application
+		// would be accepted.
+		v.required("auth.oidc.issuer", c.OIDC.Issuer)
+		v.required("auth.oidc.audience", c.OIDC.Audience)
+		v.required("auth.oidc.userClaim", c.OIDC.UserClaim)
+	}
+}
+
+func (l QuotaLimits) validate(v *validator, key string) {
+	v.nonNegative(key+".requestsPerMinute", l.RequestsPerMinute)
+	v.nonNegative(key+".concurrentGenerations", l.ConcurrentGenerations)
+	v.nonNegative(key+".dailyTokens", l.DailyTokens)
+}
+
+func (c AIModelConfig) validate(v *validator, key string) {
+	v.oneOf(key+".name", c.Name, SupportedModels)
+	v.oneOf(key+".location", c.Location, ModelLocations)
+
+	if t := c.Temperature; t < 0 || t > 2 {
+		v.add("%s.temperature must be between 0 and 2, got %v", key, t)
+	}
+
+	if n := c.MaxOutputTokens; n < 1 || n > 65536 {
+		v.add("%s.maxOutputTokens must be between 1 and 65536, got %d", key, n)
+	}
+}
+
+func validStatusClass(status string) bool {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if status == "network" {
+		return true
+	}
+
+	if len(status) != 3 || status[0] < '1' || status[0] > '5' {
+		return false
+	}
+
+	if status[1:] == "xx" {
+		return true
+	}
+
+	return strings.Trim(status[1:], "0123456789") == ""
+}
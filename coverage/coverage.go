@@ -0,0 +1,118 @@
+package coverage
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Report lists which endpoints of a specification the generated files call.
+type Report struct {
+	Spec       string     `json:"spec"`
+	Version    string     `json:"version"`
+	Covered    []Endpoint `json:"covered"`
+	Missing    []Endpoint `json:"missing"`
+	Reprompted bool       `json:"reprompted"`
+}
+
+func (r Report) Complete() bool {
+	return len(r.Missing) == 0
+}
+
+// nearby is how many lines around a path the method of an endpoint is looked
+// for, when the path has several methods.
+const nearby = 8
+
+// Analyze finds the endpoints of the spec used in contents. An endpoint is
+// covered when its path appears in a file, path parameters matching any
+// value, and when the path has several methods, the method is named close to
+// it.
+func (s *Spec) Analyze(contents []string) Report {
+	report := Report{Spec: s.Title, Version: s.Version}
+
+	methodsOf := map[string]int{}
+	for _, endpoint := range s.Endpoints {
+		methodsOf[endpoint.Path]++
+	}
+
+	for _, endpoint := range s.Endpoints {
+		pattern := s.pathPattern(endpoint.Path)
+		method := methodPattern(endpoint.Method)
+
+		covered := slices.ContainsFunc(contents, func(content string) bool {
+			for _, match := range pattern.FindAllStringSubmatchIndex(content, -1) {
+				path := content[match[2]:match[3]]
+				if path != endpoint.Path && s.literal(path) {
+					continue
+				}
+
+				if methodsOf[endpoint.Path] == 1 || method.MatchString(around(content, match[2], nearby)) {
+					return true
+				}
+			}
+			return false
+		})
+
+		if covered {
+			report.Covered = append(report.Covered, endpoint)
+		} else {
+			report.Missing = append(report.Missing, endpoint)
+		}
+	}
+
+	return report
+}
+
+var parameter = regexp.MustCompile(`\{[^}]*\}`)
+
+// pathPattern matches the path of an endpoint, a parameter stands for any
+// value up to the next separator, including none when the code appends it
+// to the literal. The path must not continue after the match.
+func (s *Spec) pathPattern(path string) *regexp.Regexp {
+	var pattern strings.Builder
+	last := 0
+	for _, loc := range parameter.FindAllStringIndex(path, -1) {
+		pattern.WriteString(regexp.QuoteMeta(path[last:loc[0]]))
+		pattern.WriteString("[^/\\s\"'`?#]*")
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(path[last:]))
+
+	return regexp.MustCompile(`(` + pattern.String() + `)(?:[^A-Za-z0-9_\-/]|$)`)
+}
+
+// literal reports whether path is the exact path of one of the endpoints, so
+// /applications/me is not taken for /applications/{id}.
+func (s *Spec) literal(path string) bool {
+	return slices.ContainsFunc(s.Endpoints, func(e Endpoint) bool {
+		return e.Path == path
+	})
+}
+
+func methodPattern(method string) *regexp.Regexp {
+	title := method[:1] + strings.ToLower(method[1:])
+	return regexp.MustCompile(`(?i:\b` + method + `\b)|Method` + title + `\b`)
+}
+
+// around returns the lines within n lines of offset.
+func around(content string, offset int, n int) string {
+	start := offset
+	for i := 0; i <= n && start > 0; i++ {
+		start = strings.LastIndex(content[:start-1], "\n") + 1
+		if start == 0 {
+			break
+		}
+	}
+
+	end := offset
+	for i := 0; i <= n && end < len(content); i++ {
+		next := strings.Index(content[end:], "\n")
+		if next < 0 {
+			end = len(content)
+			break
+		}
+		end += next + 1
+	}
+
+	return content[start:end]
+}
@@ -0,0 +1,154 @@
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Endpoint is one operation of an API specification.
+type Endpoint struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	OperationId string `json:"operationId,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+}
+
+func (e Endpoint) String() string {
+	return e.Method + " " + e.Path
+}
+
+// Spec is an OpenAPI (or Swagger 2.0) specification reduced to what the
+// coverage analysis needs.
+type Spec struct {
+	Title     string
+	Version   string
+	Endpoints []Endpoint
+}
+
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+type document struct {
+	Swagger string `json:"swagger"`
+	OpenApi string `json:"openapi"`
+	Info    struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+type operation struct {
+	OperationId string `json:"operationId"`
+	Summary     string `json:"summary"`
+}
+
+// Library holds the API specifications found in a directory.
+type Library struct {
+	specs []*Spec
+}
+
+// Load parses every *.json file in dir that is an OpenAPI or Swagger
+// document, other JSON files are skipped.
+func Load(dir string) (*Library, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	library := &Library{}
+	for _, path := range paths {
+		spec, err := parse(path)
+		if err != nil {
+			return nil, err
+		}
+		if spec != nil {
+			library.specs = append(library.specs, spec)
+		}
+	}
+
+	return library, nil
+}
+
+func parse(path string) (*Spec, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := document{}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if doc.Swagger == "" && doc.OpenApi == "" {
+		return nil, nil
+	}
+
+	spec := &Spec{Title: doc.Info.Title, Version: doc.Info.Version}
+	for route, item := range doc.Paths {
+		for method, raw := range item {
+			if !slices.Contains(methods, method) {
+				continue
+			}
+
+			op := operation{}
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("%s: %s %s: %w", path, method, route, err)
+			}
+
+			spec.Endpoints = append(spec.Endpoints, Endpoint{
+				Method:      strings.ToUpper(method),
+				Path:        route,
+				OperationId: op.OperationId,
+				Summary:     op.Summary,
+			})
+		}
+	}
+
+	slices.SortFunc(spec.Endpoints, func(a, b Endpoint) int {
+		return strings.Compare(a.Path+" "+a.Method, b.Path+" "+b.Method)
+	})
+	return spec, nil
+}
+
+// Get finds the specification of an API by title, ignoring case, spacing
+// and punctuation, e.g. "showcase api" finds "Showcase API".
+func (l *Library) Get(api string) (*Spec, bool) {
+	name := normalize(api)
+	if name == "" {
+		return nil, false
+	}
+
+	for _, spec := range l.specs {
+		if normalize(spec.Title) == name {
+			return spec, true
+		}
+	}
+	return nil, false
+}
+
+// Titles returns the titles of the loaded specifications.
+func (l *Library) Titles() []string {
+	titles := make([]string, len(l.specs))
+	for i, spec := range l.specs {
+		titles[i] = spec.Title
+	}
+
+	slices.Sort(titles)
+	return titles
+}
+
+func normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, s)
+}
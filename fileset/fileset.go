@@ -0,0 +1,116 @@
+package fileset
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Rules a generated file can violate, a file violating any of them is
+// dropped from the set.
+const (
+	InvalidPath   = "invalid_path"
+	Duplicate     = "duplicate"
+	CaseCollision = "case_collision"
+	FileTooLarge  = "file_too_large"
+	TotalTooLarge = "total_too_large"
+)
+
+// Violation explains why a generated file was dropped. Path is the path as
+// the model wrote it.
+type Violation struct {
+	Path    string `json:"path"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Limits caps the size of the generated files in bytes, zero means no limit.
+type Limits struct {
+	MaxFileSize  int
+	MaxTotalSize int
+}
+
+// Entry is a generated file as far as normalization is concerned.
+type Entry struct {
+	Path string
+	Size int
+}
+
+var drive = regexp.MustCompile(`^[A-Za-z]:`)
+
+// CleanPath returns the canonical form of a relative path: forward slashes,
+// no "." or empty segments. Absolute paths and paths escaping the project
+// root are rejected.
+func CleanPath(p string) (string, error) {
+	p = strings.ReplaceAll(strings.TrimSpace(p), `\`, "/")
+
+	if p == "" {
+		return "", errors.New("path is empty")
+	}
+	if strings.HasPrefix(p, "/") || drive.MatchString(p) {
+		return "", errors.New("absolute paths are not allowed")
+	}
+
+	p = path.Clean(p)
+	if p == "." {
+		return "", errors.New("path does not name a file")
+	}
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", errors.New("path traversal is not allowed")
+	}
+	return p, nil
+}
+
+// Normalize returns the canonical path of every entry, or an empty string
+// for the ones that are dropped, together with the reasons they were.
+// Of several entries with the same path, or paths only differing in case,
+// the first one is kept.
+func Normalize(entries []Entry, limits Limits) ([]string, []Violation) {
+	paths := make([]string, len(entries))
+	var violations []Violation
+
+	drop := func(entry Entry, rule string, format string, args ...any) {
+		violations = append(violations, Violation{
+			Path:    entry.Path,
+			Rule:    rule,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	seen := map[string]string{}
+	total := 0
+
+	for i, entry := range entries {
+		clean, err := CleanPath(entry.Path)
+		if err != nil {
+			drop(entry, InvalidPath, "%v", err)
+			continue
+		}
+
+		if first, ok := seen[strings.ToLower(clean)]; ok {
+			if first == clean {
+				drop(entry, Duplicate, "%s is generated more than once", clean)
+			} else {
+				drop(entry, CaseCollision, "%s only differs in case from %s", clean, first)
+			}
+			continue
+		}
+
+		if limits.MaxFileSize > 0 && entry.Size > limits.MaxFileSize {
+			drop(entry, FileTooLarge, "%s is %d bytes, the limit is %d", clean, entry.Size, limits.MaxFileSize)
+			continue
+		}
+		if limits.MaxTotalSize > 0 && total+entry.Size > limits.MaxTotalSize {
+			drop(entry, TotalTooLarge, "%s would bring the total to %d bytes, the limit is %d", clean, total+entry.Size, limits.MaxTotalSize)
+			continue
+		}
+
+		seen[strings.ToLower(clean)] = clean
+		total += entry.Size
+		paths[i] = clean
+	}
+
+	return paths, violations
+}
@@ -0,0 +1,43 @@
+// With authentication enabled on the server, the portal sends an API key with
+// every request. It is asked for on the first 401 and kept for the session.
+const API_KEY_HEADER = 'X-API-Key'
+const STORAGE_KEY = 'ing-portal-api-key'
+
+let asking: Promise<string | null> | null = null
+
+function askForKey(): Promise<string | null> {
+  // Concurrent requests failing together share one prompt.
+  asking ??= Promise.resolve().then(() => {
+    const key = window.prompt('This portal requires an API key:')?.trim() || null
+    if (key) {
+      sessionStorage.setItem(STORAGE_KEY, key)
+    }
+    asking = null
+    return key
+  })
+  return asking
+}
+
+function withKey(init: RequestInit, key: string | null): RequestInit {
+  if (!key) return init
+
+  const headers = new Headers(init.headers)
+  headers.set(API_KEY_HEADER, key)
+  return {...init, headers}
+}
+
+export async function apiFetch(input: string, init: RequestInit = {}): Promise<Response> {
+  const stored = sessionStorage.getItem(STORAGE_KEY)
+  const res = await fetch(input, withKey(init, stored))
+  if (res.status !== 401) return res
+
+  // The stored key was rejected, or none was sent yet.
+  if (stored) {
+    sessionStorage.removeItem(STORAGE_KEY)
+  }
+
+  const key = await askForKey()
+  if (!key) return res
+
+  return fetch(input, withKey(init, key))
+}
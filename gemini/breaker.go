@@ -0,0 +1,117 @@
+package gemini
+
+import (
+	"ai-test/config"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// BreakerSnapshot is a point-in-time view of the circuit breaker, used by the
+// health endpoint.
+type BreakerSnapshot struct {
+	State    BreakerState `json:"state"`
+	Failures int          `json:"failures"`
+	OpenedAt *time.Time   `json:"openedAt,omitempty"`
+}
+
+// circuitBreaker stops calling the model after FailureThreshold consecutive
+// upstream failures. Once OpenTimeout has passed a single trial call is let
+// through: success closes the breaker again, failure reopens it.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{
+		state: BreakerClosed,
+	}
+}
+
+// allow reports whether a call may go through, and whether it is the trial
+// call of a half open breaker.
+func (b *circuitBreaker) allow(conf config.CircuitBreakerConfig) (trial bool, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < conf.OpenTimeout {
+			return false, false
+		}
+		b.state = BreakerHalfOpen
+		return true, true
+	case BreakerHalfOpen:
+		// Only the trial call may go through until it reports back.
+		return false, false
+	default:
+		return false, true
+	}
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != BreakerClosed {
+		log.Info("Circuit breaker closed")
+	}
+
+	b.state = BreakerClosed
+	b.failures = 0
+}
+
+// abandon reopens a half open breaker whose trial call ended without telling
+// whether the upstream recovered, so the next call is a trial again.
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+	}
+}
+
+func (b *circuitBreaker) failure(conf config.CircuitBreakerConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+
+	if b.state == BreakerHalfOpen || (conf.FailureThreshold > 0 && b.failures >= conf.FailureThreshold) {
+		if b.state != BreakerOpen {
+			log.Warnf("Circuit breaker opened after %d failures", b.failures)
+		}
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *circuitBreaker) snapshot() BreakerSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snapshot := BreakerSnapshot{
+		State:    b.state,
+		Failures: b.failures,
+	}
+
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		snapshot.OpenedAt = &openedAt
+	}
+
+	return snapshot
+}
@@ -0,0 +1,130 @@
+package gemini
+
+import (
+	"ai-test/coverage"
+	"ai-test/fileset"
+	"ai-test/metrics"
+	"ai-test/server/responses"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v3/log"
+	"google.golang.org/genai"
+)
+
+// generation is what a code generation sent to the model and got back, a
+// follow-up prompt continues from it.
+type generation struct {
+	profile      *modelProfile
+	systemPrompt string
+	prompt       string
+	answer       string
+}
+
+// checkCoverage reports which endpoints of the requested API the generated
+// files call. When asked to, the model is prompted once more for the missing
+// ones and the files it returns replace or extend the generated ones.
+func (client *Client) checkCoverage(ctx context.Context, t *aiTools, request GenerationRequest, previous generation, response *responses.GenerationResponse) {
+	spec, ok := t.specs.Get(request.Api)
+	if !ok {
+		return
+	}
+
+	report := spec.Analyze(response.Contents())
+	if !report.Complete() {
+		log.Warnf("Generated files do not cover %d endpoint(s) of %s", len(report.Missing), spec.Title)
+	}
+
+	if !report.Complete() && request.RepromptMissing(t.conf.Coverage) {
+		added, err := client.promptMissing(ctx, t, previous, report.Missing)
+		if err != nil {
+			log.Errorf("Could not prompt for the missing endpoints, keeping the generated files: %v", err)
+		} else {
+			mergeFiles(ctx, t, response, added)
+			report = spec.Analyze(response.Contents())
+		}
+		report.Reprompted = true
+	}
+
+	response.Coverage = &report
+}
+
+func (client *Client) promptMissing(ctx context.Context, t *aiTools, previous generation, missing []coverage.Endpoint) (*responses.GenerationResponse, error) {
+	var prompt strings.Builder
+	prompt.WriteString("The client does not implement these endpoints of the API specification yet:\n")
+	for _, endpoint := range missing {
+		fmt.Fprintf(&prompt, "- %s: %s\n", endpoint, endpoint.Summary)
+	}
+	prompt.WriteString("Implement them and return every file you add or change in full.")
+
+	models, err := client.models(previous.profile.model.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	stageCtx, cancel := withTimeout(ctx, t.conf.Vertex.Timeouts.Generation)
+	defer cancel()
+
+	contents := []*genai.Content{
+		genai.NewContentFromText(previous.prompt, genai.RoleUser),
+		genai.NewContentFromText(previous.answer, genai.RoleModel),
+		genai.NewContentFromText(prompt.String(), genai.RoleUser),
+	}
+
+	generated, err := callModel(stageCtx, t.conf.Vertex, func(ctx context.Context) (*genai.GenerateContentResponse, error) {
+		return models.Models.GenerateContent(
+			ctx,
+			previous.profile.model.Name,
+			contents,
+			previous.profile.withSystemPrompt(previous.systemPrompt),
+		)
+	})
+	if err == nil {
+		err = checkResponse(generated)
+	}
+	if err != nil {
+		gerr := classifyError(err)
+		metrics.ModelError(string(gerr.Kind))
+		return nil, gerr
+	}
+
+	added, herr := client.runJsonFormattingPrompt(ctx, t, previous.profile, generated.Text())
+	setStatus(ctx, responses.Formatting)
+	if herr != nil {
+		return nil, fmt.Errorf("converting the answer: %s", herr.Message)
+	}
+
+	postProcess(ctx, t, added)
+	return added, nil
+}
+
+// mergeFiles replaces the files of response by the added ones with the same
+// path and appends the others. Violations of replaced paths no longer apply,
+// and the merged files are normalized again since the limits hold for the
+// whole set.
+func mergeFiles(ctx context.Context, t *aiTools, response *responses.GenerationResponse, added *responses.GenerationResponse) {
+	replaced := map[string]bool{}
+	for _, file := range added.Files {
+		replaced[strings.ToLower(file.FilePath)] = true
+
+		i := slices.IndexFunc(response.Files, func(f responses.GeneratedFile) bool {
+			return f.FilePath == file.FilePath
+		})
+
+		if i >= 0 {
+			response.Files[i] = file
+		} else {
+			response.Files = append(response.Files, file)
+		}
+	}
+
+	violations := slices.DeleteFunc(response.Violations, func(violation fileset.Violation) bool {
+		clean, err := fileset.CleanPath(violation.Path)
+		return err == nil && replaced[strings.ToLower(clean)]
+	})
+	response.Violations = append(violations, added.Violations...)
+
+	normalizeFiles(ctx, t, response)
+}
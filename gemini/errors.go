@@ -0,0 +1,129 @@
+package gemini
+
+import (
+	"ai-test/server/errors"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/genai"
+)
+
+type ErrorKind string
+
+const (
+	QuotaExceeded       ErrorKind = "quota_exceeded"
+	SafetyBlocked       ErrorKind = "safety_blocked"
+	Timeout             ErrorKind = "timeout"
+	InvalidOutput       ErrorKind = "invalid_output"
+	UpstreamUnavailable ErrorKind = "upstream_unavailable"
+	CircuitOpen         ErrorKind = "circuit_open"
+	Canceled            ErrorKind = "canceled"
+)
+
+// Error is returned by the model calls of this package. Kind decides which
+// HTTP error is reported to the user, Err keeps the original cause for logs.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return string(e.Kind)
+	}
+	return fmt.Sprintf("%s: %v", e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) HttpError() *errors.HttpError {
+	var herr errors.HttpError
+
+	switch e.Kind {
+	case QuotaExceeded:
+		herr = errors.QuotaExceededError
+	case SafetyBlocked:
+		herr = errors.SafetyBlockedError
+	case Timeout:
+		herr = errors.TimeoutError
+	case InvalidOutput:
+		herr = errors.InvalidOutputError
+	case CircuitOpen:
+		herr = errors.CircuitOpenError
+	case Canceled:
+		herr = errors.CanceledError
+	default:
+		herr = errors.UpstreamUnavailableError
+	}
+
+	return &herr
+}
+
+func newError(kind ErrorKind, format string, args ...any) *Error {
+	return &Error{
+		Kind: kind,
+		Err:  fmt.Errorf(format, args...),
+	}
+}
+
+// classifyError maps an error returned by the genai SDK to an Error.
+func classifyError(err error) *Error {
+	var gerr *Error
+	if stderrors.As(err, &gerr) {
+		return gerr
+	}
+
+	if stderrors.Is(err, context.DeadlineExceeded) {
+		return &Error{Kind: Timeout, Err: err}
+	}
+
+	if stderrors.Is(err, context.Canceled) {
+		return &Error{Kind: Canceled, Err: err}
+	}
+
+	var apiErr genai.APIError
+	if stderrors.As(err, &apiErr) {
+		switch {
+		case apiErr.Code == http.StatusTooManyRequests || apiErr.Status == "RESOURCE_EXHAUSTED":
+			return &Error{Kind: QuotaExceeded, Err: err}
+		case apiErr.Code == http.StatusGatewayTimeout || apiErr.Status == "DEADLINE_EXCEEDED":
+			return &Error{Kind: Timeout, Err: err}
+		}
+	}
+
+	return &Error{Kind: UpstreamUnavailable, Err: err}
+}
+
+// checkResponse reports responses that carry no usable text, either because
+// the prompt or the candidate was blocked or because the model returned nothing.
+func checkResponse(response *genai.GenerateContentResponse) error {
+	if response == nil {
+		return newError(InvalidOutput, "empty response")
+	}
+
+	if feedback := response.PromptFeedback; feedback != nil && feedback.BlockReason != "" {
+		return newError(SafetyBlocked, "prompt blocked: %s", feedback.BlockReason)
+	}
+
+	if len(response.Candidates) == 0 {
+		return newError(InvalidOutput, "response has no candidates")
+	}
+
+	switch response.Candidates[0].FinishReason {
+	case genai.FinishReasonSafety,
+		genai.FinishReasonBlocklist,
+		genai.FinishReasonProhibitedContent,
+		genai.FinishReasonSPII:
+		return newError(SafetyBlocked, "candidate blocked: %s", response.Candidates[0].FinishReason)
+	}
+
+	if response.Text() == "" {
+		return newError(InvalidOutput, "response has no text")
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package gemini
+
+import (
+	"ai-test/auth"
+	"ai-test/identity"
+	"ai-test/languages"
+	"ai-test/sandbox"
+	"ai-test/server/responses"
+	"context"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+// runClient runs the generated client against the mock sandbox when enabled,
+// for administrators only. Only Go clients using the sandbox can be run.
+func runClient(ctx context.Context, t *aiTools, request GenerationRequest, response *responses.GenerationResponse) {
+	if !t.conf.Execution.Enabled {
+		return
+	}
+	if user, ok := auth.FromContext(ctx); !ok || !user.Admin {
+		return
+	}
+
+	id := request.Identity()
+
+	if language, ok := languages.Get(request.Language); !ok || language.Id != "go" {
+		response.Execution = &sandbox.Report{Error: "only generated Go clients can be run"}
+		return
+	}
+	if id.Environment != identity.Sandbox {
+		response.Execution = &sandbox.Report{Error: "only clients using the sandbox can be run"}
+		return
+	}
+
+	files := make([]sandbox.File, len(response.Files))
+	for i, file := range response.Files {
+		files[i] = sandbox.File{Path: file.FilePath, Code: file.Code}
+	}
+
+	report := sandbox.Run(ctx, files, sandbox.Options{
+		ClientId:        id.ClientId,
+		Host:            id.Host,
+		CertificatePath: id.CertificatePath,
+		KeyPath:         id.KeyPath,
+		Timeout:         t.conf.Execution.Timeout,
+	})
+
+	if !report.Passed() {
+		log.Warnf("Generated client did not pass against the mock sandbox: built %v, exit code %d, authenticated %v, endpoints %v %s",
+			report.Built, report.ExitCode, report.Authenticated, report.Endpoints, report.Error)
+	}
+	response.Execution = &report
+}
@@ -1,13 +1,19 @@
 package gemini
 
 import (
+	"ai-test/config"
+	"ai-test/languages"
+	"ai-test/metrics"
 	"ai-test/server/errors"
 	"ai-test/server/responses"
+	"ai-test/store"
 	"ai-test/util"
 	"ai-test/util/level"
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v3/log"
@@ -15,61 +21,229 @@ import (
 )
 
 type Client struct {
-	client *genai.Client
-	Status responses.GenerationStatus
-	chat   *genai.Chat
+	mu      sync.Mutex
+	clients map[string]*genai.Client
 }
 
-var Files []responses.GeneratedFile
-var originalPrompt string
+var watchConfig sync.Once
 
 func NewClient() *Client {
-	ctx := context.Background()
+	conf := config.Get()
 
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		Project:  conf.Vertex.Project.Id,
-		Location: conf.Vertex.Model.Location,
+	configureAITools(conf)
+	watchConfig.Do(func() {
+		config.OnChange(configureAITools)
+	})
+
+	client := &Client{
+		clients: map[string]*genai.Client{},
+	}
+
+	// A client that could not be created is retried on use, the server
+	// reports itself not ready meanwhile.
+	util.HandleError("Failed to create client: %v", client.Ready(), level.ERROR)
+
+	return client
+}
+
+// Ready creates the provider client of the default profile, if it was not
+// created already, and returns why that failed.
+func (client *Client) Ready() error {
+	_, model, _ := config.Get().Vertex.Profile("")
+	_, err := client.models(model.Location)
+	return err
+}
+
+// models returns the genai client for a Vertex AI location. Profiles may use
+// different locations, so one client is created lazily per location.
+func (client *Client) models(location string) (*genai.Client, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if c, ok := client.clients[location]; ok {
+		return c, nil
+	}
+
+	c, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		Project:  config.Get().Vertex.Project.Id,
+		Location: location,
 		Backend:  genai.BackendVertexAI,
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	client.clients[location] = c
+	return c, nil
+}
+
+// GenerateCode serves the request from the generation cache when possible and
+// falls back to RunCodeGenerationPrompt otherwise.
+func (client *Client) GenerateCode(ctx context.Context, request GenerationRequest) (*responses.GenerationResponse, *errors.HttpError) {
+	ctx = withStageTimer(ctx)
 
-	util.HandleError("Failed to create client: %v", err, level.FATAL)
+	// The status of the previous generation must not show through until this
+	// one reaches the model.
+	setStatus(ctx, responses.NotStarted)
 
-	configureAITools()
+	t := tools.Load()
+	profile, herr := t.profile(request.Profile)
+	if herr != nil {
+		return nil, herr
+	}
+
+	systemPrompt, herr := t.systemPrompt(request.PromptVersion, profile)
+	if herr != nil {
+		return nil, herr
+	}
 
-	return &Client{
-		client: client,
+	prompt := request.BuildPrompt()
+	key := request.cacheKey(t.conf, profile.model, systemPrompt.Version)
+
+	if !request.BypassCache() {
+		if cached, ok := t.generationCache.Get(key); ok {
+			response := responses.GenerationResponse{}
+			if err := json.Unmarshal(cached, &response); err == nil {
+				log.Infof("Serving cached generation %s", key)
+
+				response.Time = time.Now()
+				response.Cached = true
+				runClient(ctx, t, request, &response)
+				scanSecrets(&response, t.conf.Secrets, request)
+				saveGeneration(ctx, prompt, &response)
+				setStatus(ctx, responses.Done)
+				return &response, nil
+			}
+		}
 	}
+
+	response, herr := client.RunCodeGenerationPrompt(ctx, request)
+	if herr != nil {
+		setStatus(ctx, responses.NotStarted)
+		return nil, herr
+	}
+
+	if len(response.Files) > 0 {
+		encoded, err := json.Marshal(response)
+		util.HandleError("Could not cache generated response: %v", err, level.WARN)
+		if err == nil {
+			t.generationCache.Set(key, encoded)
+		}
+	}
+
+	// Running the client and redaction happen after caching, whether to do
+	// either is up to each request.
+	runClient(ctx, t, request, response)
+	scanSecrets(response, t.conf.Secrets, request)
+	saveGeneration(ctx, prompt, response)
+	setStatus(ctx, responses.Done)
+	return response, nil
 }
 
-func (client *Client) RunCodeGenerationPrompt(prompt string) (*responses.GenerationResponse, *errors.HttpError) {
-	originalPrompt = prompt
+func (client *Client) RunCodeGenerationPrompt(ctx context.Context, request GenerationRequest) (*responses.GenerationResponse, *errors.HttpError) {
+	t := tools.Load()
+	profile, herr := t.profile(request.Profile)
+	if herr != nil {
+		return nil, herr
+	}
+
+	systemPrompt, herr := t.systemPrompt(request.PromptVersion, profile)
+	if herr != nil {
+		return nil, herr
+	}
+
+	renderedPrompt, err := systemPrompt.Render(request.promptData())
+	if err != nil {
+		util.HandleError("Error rendering system prompt: %v", err, level.ERROR)
+		return nil, &errors.InternalServerError
+	}
+
+	models, err := client.models(profile.model.Location)
+	if err != nil {
+		return nil, modelError("Error creating client: %v", err)
+	}
+
+	prompt := request.BuildPrompt()
+
+	stageCtx, cancel := withTimeout(ctx, t.conf.Vertex.Timeouts.Generation)
+	defer cancel()
 
-	ctx := context.Background()
 	start := time.Now()
 
-	client.Status = responses.Generating
+	setStatus(ctx, responses.Generating)
 
-	generatedResponse, err := client.client.Models.GenerateContent(
-		ctx,
-		conf.Vertex.Model.Name,
-		genai.Text(prompt),
-		groundedSearchConfig,
-	)
+	generatedResponse, err := callModel(stageCtx, t.conf.Vertex, func(ctx context.Context) (*genai.GenerateContentResponse, error) {
+		return models.Models.GenerateContent(
+			ctx,
+			profile.model.Name,
+			genai.Text(prompt),
+			profile.withSystemPrompt(renderedPrompt),
+		)
+	})
+	if err == nil {
+		err = checkResponse(generatedResponse)
+	}
 
-	client.Status = responses.Generated
+	if err != nil {
+		setStatus(ctx, responses.NotStarted)
+		return nil, modelError("Error generating response: %v", err)
+	}
+
+	setStatus(ctx, responses.Generated)
 
 	log.Infof("Generated response in %v", time.Since(start))
-	util.HandleError("Error generating response: %v", err, level.ERROR)
 
 	groundedText := generatedResponse.Text()
 
-	return client.runJsonFormattingPrompt(groundedText)
+	response, herr := client.runJsonFormattingPrompt(ctx, t, profile, groundedText)
+	if herr != nil {
+		return nil, herr
+	}
+
+	response.Profile = profile.name
+	response.PromptVersion = systemPrompt.Version
+
+	setStatus(ctx, responses.Formatting)
+
+	postProcess(ctx, t, response)
+
+	client.checkCoverage(ctx, t, request, generation{
+		profile:      profile,
+		systemPrompt: renderedPrompt,
+		prompt:       prompt,
+		answer:       groundedText,
+	}, response)
+
+	if language, ok := languages.Get(request.Language); ok {
+		report := language.Validate(response.FilePaths())
+		if !report.Valid() {
+			log.Warnf("Generated files do not match the %s manifest: %+v", language.Name, report)
+		}
+		response.Manifest = &report
+	}
+
+	clientIdentity := request.Identity()
+	response.ClientIdentity = &clientIdentity
+
+	identityReport := clientIdentity.Verify(response.Contents())
+	if !identityReport.Valid() {
+		log.Warnf("Generated files do not use the requested client identity: %v", identityReport.Problems)
+	}
+	response.IdentityCheck = &identityReport
+
+	return response, nil
 }
 
-func (client *Client) StartChatSession() *errors.HttpError {
-	ctx := context.Background()
+func (client *Client) StartChatSession(ctx context.Context, profileName string) *errors.HttpError {
+	generation, err := lastGeneration(userOf(ctx))
+	if stderrors.Is(err, store.ErrNotFound) {
+		generation = &Generation{Response: &responses.GenerationResponse{}}
+	} else if err != nil {
+		util.HandleError("Error loading last generation: %v", err, level.ERROR)
+		return &errors.InternalServerError
+	}
 
-	modelResponse, err := json.Marshal(Files)
+	modelResponse, err := json.Marshal(generation.Response.Files)
 	if err != nil {
 		util.HandleError("Error marshalling model response: %v", err, level.ERROR)
 		return &errors.InternalServerError
@@ -79,7 +253,7 @@ func (client *Client) StartChatSession() *errors.HttpError {
 		{
 			Role: genai.RoleUser,
 			Parts: []*genai.Part{
-				{Text: originalPrompt},
+				{Text: generation.Prompt},
 			},
 		},
 		{
@@ -90,56 +264,162 @@ func (client *Client) StartChatSession() *errors.HttpError {
 		},
 	}
 
-	chatSession, err := client.client.Chats.Create(ctx, conf.Vertex.Model.Name, nil, history)
-	if err != nil {
-		util.HandleError("Error creating chat session: %v", err, level.ERROR)
-		return &errors.InternalServerError
+	profile, herr := tools.Load().profile(profileName)
+	if herr != nil {
+		return herr
 	}
 
-	client.chat = chatSession
+	if _, herr := client.createChat(ctx, profile, history); herr != nil {
+		return herr
+	}
+
+	if err := saveChat(ctx, chatSession{Profile: profile.name, History: history}); err != nil {
+		util.HandleError("Error saving chat session: %v", err, level.ERROR)
+		return &errors.InternalServerError
+	}
 
 	return nil
 }
 
-func (client *Client) SendMessage(message string) (*responses.ChatResponse, *errors.HttpError) {
-	ctx := context.Background()
+func (client *Client) SendMessage(ctx context.Context, profileName string, message string) (*responses.ChatResponse, *errors.HttpError) {
+	session, err := loadChat(ctx)
+	if stderrors.Is(err, store.ErrNotFound) {
+		return nil, &errors.ChatNotStartedError
+	}
+	if err != nil {
+		util.HandleError("Error loading chat session: %v", err, level.ERROR)
+		return nil, &errors.InternalServerError
+	}
+
+	t := tools.Load()
+
+	// Switching profiles mid-conversation moves the history to a chat on the
+	// newly selected model.
+	if profileName == "" {
+		profileName = session.Profile
+	}
+
+	profile, herr := t.profile(profileName)
+	if herr != nil {
+		return nil, herr
+	}
+
+	chat, herr := client.createChat(ctx, profile, session.History)
+	if herr != nil {
+		return nil, herr
+	}
+
+	ctx, cancel := withTimeout(ctx, t.conf.Vertex.Timeouts.Chat)
+	defer cancel()
 
 	prompt := genai.Part{
 		Text: message,
 	}
 
-	response, err := client.chat.SendMessage(ctx, prompt)
+	response, err := callModel(ctx, t.conf.Vertex, func(ctx context.Context) (*genai.GenerateContentResponse, error) {
+		return chat.SendMessage(ctx, prompt)
+	})
+	if err == nil {
+		err = checkResponse(response)
+	}
+
 	if err != nil {
+		return nil, modelError("Error sending chat message: %v", err)
+	}
+
+	if err := saveChat(ctx, chatSession{Profile: profile.name, History: chat.History(false)}); err != nil {
+		util.HandleError("Error saving chat session: %v", err, level.ERROR)
 		return nil, &errors.InternalServerError
 	}
 
 	return responses.NewChatResponse(response.Text()), nil
 }
 
-func (client *Client) runJsonFormattingPrompt(prompt string) (*responses.GenerationResponse, *errors.HttpError) {
-	ctx := context.Background()
+// createChat restores a chat from its history. Any process may serve the
+// next message, so chats are recreated for every one of them.
+func (client *Client) createChat(ctx context.Context, profile *modelProfile, history []*genai.Content) (*genai.Chat, *errors.HttpError) {
+	models, err := client.models(profile.model.Location)
+	if err != nil {
+		return nil, modelError("Error creating client: %v", err)
+	}
+
+	chatConfig := &genai.GenerateContentConfig{
+		Temperature:     &profile.model.Temperature,
+		MaxOutputTokens: profile.model.MaxOutputTokens,
+	}
+
+	chat, err := models.Chats.Create(ctx, profile.model.Name, chatConfig, history)
+	if err != nil {
+		return nil, modelError("Error creating chat session: %v", err)
+	}
+
+	return chat, nil
+}
+
+func (client *Client) runJsonFormattingPrompt(ctx context.Context, t *aiTools, profile *modelProfile, prompt string) (*responses.GenerationResponse, *errors.HttpError) {
+	ctx, cancel := withTimeout(ctx, t.conf.Vertex.Timeouts.Formatting)
+	defer cancel()
+
 	start := time.Now()
 
-	client.Status = responses.Formatting
+	setStatus(ctx, responses.Converting)
 
-	generatedResponse, err := client.client.Models.GenerateContent(
-		ctx,
-		conf.Vertex.Model.Name,
-		genai.Text(fmt.Sprintf("Format this from markdown to json: %s", prompt)),
-		formattingConfig,
-	)
+	generatedResponse, err := callModel(ctx, t.conf.Vertex, func(ctx context.Context) (*genai.GenerateContentResponse, error) {
+		models, err := client.models(profile.model.Location)
+		if err != nil {
+			return nil, err
+		}
+
+		return models.Models.GenerateContent(
+			ctx,
+			profile.model.Name,
+			genai.Text(fmt.Sprintf("Format this from markdown to json: %s", prompt)),
+			profile.formattingConfig,
+		)
+	})
+	if err == nil {
+		err = checkResponse(generatedResponse)
+	}
+
+	if err != nil {
+		setStatus(ctx, responses.NotStarted)
+		return nil, modelError("Error while formatting generated response: %v", err)
+	}
 
 	log.Infof("Formatted response in %v", time.Since(start))
-	util.HandleError("Error while formatting generated response: %v", err, level.ERROR)
 
 	formattedText := generatedResponse.Text()
 
 	response := responses.GenerationResponse{}
 
-	err = json.Unmarshal([]byte(formattedText), &response)
+	if err = json.Unmarshal([]byte(formattedText), &response); err != nil {
+		setStatus(ctx, responses.NotStarted)
+		return nil, modelError("Error parsing formatted response: %v", newError(InvalidOutput, "%v", err))
+	}
 
-	Files = response.Files
+	if len(response.Files) == 0 {
+		setStatus(ctx, responses.NotStarted)
+		return nil, modelError("Error parsing formatted response: %v", newError(InvalidOutput, "no files generated"))
+	}
 
 	response.Time = time.Now()
 	return &response, nil
 }
+
+// withTimeout bounds a pipeline stage. A non-positive timeout only inherits
+// the deadline of the parent context.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// modelError logs a failed model call and converts it to the HTTP error
+// matching its kind.
+func modelError(message string, err error) *errors.HttpError {
+	gerr := classifyError(err)
+	metrics.ModelError(string(gerr.Kind))
+	util.HandleError(message, gerr, level.ERROR)
+	return gerr.HttpError()
+}
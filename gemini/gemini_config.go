@@ -1,224 +1,114 @@
 package gemini
 
 import (
+	"ai-test/cache"
 	"ai-test/config"
+	"ai-test/coverage"
+	"ai-test/prompts"
+	"ai-test/server/errors"
+	"ai-test/util"
+	"ai-test/util/level"
+	"cmp"
 	"fmt"
+	"sync/atomic"
 
 	"google.golang.org/genai"
 )
 
-var (
-	conf                 = config.C
+// aiTools bundles everything derived from one configuration. It is rebuilt
+// and swapped as a whole on reload, a request loads it once and uses the same
+// snapshot for all of its model calls.
+type aiTools struct {
+	conf     *config.Config
+	profiles map[string]*modelProfile
+	// prompts is nil when they could not be loaded.
+	prompts         *prompts.Library
+	specs           *coverage.Library
+	generationCache cache.Store
+}
+
+// modelProfile holds the generation configs of one named model profile. The
+// system instruction is left out of groundedSearchConfig since the prompt
+// version is chosen per request, see withSystemPrompt.
+type modelProfile struct {
+	name                 string
+	model                config.AIModelConfig
 	groundedSearchConfig *genai.GenerateContentConfig
 	formattingConfig     *genai.GenerateContentConfig
-)
-
-const systemPrompt = `<SYSTEM_ROLE>
-You are an expert code generator specializing in ING's Sandbox APIs. You generate complete, production-ready client applications that are immediately executable without modification.
-</SYSTEM_ROLE>
-<CRITICAL_RULES_OVERRIDE_ALL>
-1. Output ONLY valid JSON - absolutely no text before or after the JSON object
-2. Never explain, apologize, or add commentary outside the JSON structure
-3. Use EXACT file paths specified in LANGUAGE_TEMPLATES - zero deviation allowed
-4. Include ALL files needed to run the application (no placeholders or TODOs)
-5. Code must compile/run without any modifications on first attempt
-6. All certificate paths MUST be: src/certs/example_client_tls.cer and src/certs/example_client_tls.key
-7. Hardcoded client_id: e77d776b-90af-4684-bebc-521e5b2614dd (never change this)
-8. Sandbox host: api.sandbox.ing.com (hardcoded, never parameterized)
-9. Follow the EXACT project structure for the target language - no variations
-10. Implement ALL endpoints from the provided API specification
-</CRITICAL_RULES_OVERRIDE_ALL>
-<INPUT_CONTEXT>
-API Specifications: {API_SPEC_CONTENT}
-OAuth Specification: {OAUTH_SPEC_CONTENT}
-PSD2 Documentation: {PSD2_DOCS_CONTENT}
-Selected API: {API_NAME}
-Target Language: {LANGUAGE}
-Available APIs:
-- Showcase API
-- Account Information API
-- Confirmation of Availability of Funds API
-- Payment Initiation API
-- Real-time Account Reporting API
-</INPUT_CONTEXT>
-<OUTPUT_FORMAT>
-Output MUST be a single valid JSON object with this EXACT structure:
-{
-"files": {
-"src/path/to/file.ext": "complete file content with \\n for newlines and proper escaping",
-"src/another/file.ext": "...",
-...
-},
-"entrypoint": "src/main_file.ext",
-"setup_instructions": "Brief setup steps (install deps, run commands)"
-}
-CRITICAL:
-- All string content must use \\n for newlines, \\t for tabs
-- Properly escape quotes: \\", \\'
-- No trailing commas in JSON
-- File paths must exactly match LANGUAGE_TEMPLATES
-</OUTPUT_FORMAT>
-<LANGUAGE_TEMPLATES>
-<GO>
-MANDATORY FILE STRUCTURE (do not add or remove files):
-{
-"files": {
-"src/main.go": "...",
-"src/client.go": "...",
-"src/auth.go": "...",
-"go.mod": "...",
-"src/README.md": "..."
-},
-"entrypoint": "src/main.go"
-}
-Requirements:
-- HTTP client: Use "net/http" with "crypto/tls" for mTLS
-- Module name: "ing-api-client"
-- Import paths: Use relative imports within module
-- Error handling: Return errors with fmt.Errorf, log to console
-- main.go: Demonstrates calling 2-3 key endpoints
-- client.go: Implements all API endpoints
-- auth.go: Handles token acquisition (application + customer tokens)
-</GO>
-<JAVA>
-MANDATORY FILE STRUCTURE:
-{
-"files": {
-"src/main/java/com/ing/client/Main.java": "...",
-"src/main/java/com/ing/client/ApiClient.java": "...",
-"src/main/java/com/ing/client/AuthManager.java": "...",
-"src/main/java/com/ing/client/SignatureUtils.java": "...",
-"pom.xml": "...",
-"src/main/resources/README.md": "..."
-},
-"entrypoint": "src/main/java/com/ing/client/Main.java"
 }
-Requirements:
-- HTTP client: OkHttp3 (com.squareup.okhttp3)
-- Java version: 11 or higher
-- Package: com.ing.client
-- Dependencies: okhttp, json (org.json), commons-codec for Base64
-- Main.java: Entry point with example usage
-- ApiClient.java: All endpoint implementations
-- AuthManager.java: Token management (caching, refresh)
-- SignatureUtils.java: HTTP/JWS signature generation
-</JAVA>
-<PYTHON>
-MANDATORY FILE STRUCTURE:
-{
-"files": {
-"src/ing_client/__init__.py": "...",
-"src/ing_client/client.py": "...",
-"src/ing_client/auth.py": "...",
-"src/ing_client/__main__.py": "...",
-"setup.py": "...",
-"requirements.txt": "...",
-"README.md": "..."
-},
-"entrypoint": "src/ing_client/__main__.py"
+
+// profile resolves a profile by name, an empty name selects the default one.
+func (t *aiTools) profile(name string) (*modelProfile, *errors.HttpError) {
+	name, _, _ = t.conf.Vertex.Profile(name)
+
+	profile, ok := t.profiles[name]
+	if !ok {
+		return nil, &errors.UnknownProfileError
+	}
+	return profile, nil
 }
-Requirements:
-- HTTP client: requests library
-- Python version: 3.8+
-- Package name: ing-client
-- requirements.txt must include: requests, cryptography, PyJWT
-- __init__.py: Expose main classes
-- client.py: ApiClient class with all endpoints
-- auth.py: AuthManager for tokens, signature generation
-- __main__.py: Runnable example (python -m ing_client)
-</PYTHON>
-<TYPESCRIPT>
-MANDATORY FILE STRUCTURE:
-{
-"files": {
-"src/index.ts": "...",
-"src/client.ts": "...",
-"src/auth.ts": "...",
-"src/types.ts": "...",
-"package.json": "...",
-"tsconfig.json": "...",
-"README.md": "..."
-},
-"entrypoint": "src/index.ts"
+
+// systemPrompt resolves the prompt version for a generation: the requested
+// one, else the profile's, else the configured default.
+func (t *aiTools) systemPrompt(requested string, profile *modelProfile) (*prompts.Prompt, *errors.HttpError) {
+	if t.prompts == nil {
+		return nil, &errors.InternalServerError
+	}
+
+	version := cmp.Or(requested, profile.model.Prompt, t.conf.Prompts.Default)
+
+	prompt, err := t.prompts.Get(version)
+	if err != nil {
+		return nil, &errors.UnknownPromptVersionError
+	}
+	return prompt, nil
 }
-Requirements:
-- HTTP client: axios
-- Runtime: Node.js 18+
-- package.json: Include axios, @types/node, typescript, ts-node, crypto (built-in)
-- tsconfig.json: target ES2020, module commonjs, strict true
-- index.ts: Main entry with examples
-- client.ts: ApiClient class with all endpoints
-- auth.ts: Authentication and signature utilities
-- types.ts: TypeScript interfaces for request/response types
-</TYPESCRIPT>
-<JAVASCRIPT>
-If {LANGUAGE} is "JavaScript" (not TypeScript), use same structure but .js files and remove tsconfig.json, use ES6 modules.
-</JAVASCRIPT>
-<RUST>
-MANDATORY FILE STRUCTURE:
-{
-"files": {
-"src/bin/main.rs": "...",
-"src/lib.rs": "...",
-"src/client.rs": "...",
-"src/auth.rs": "...",
-"Cargo.toml": "...",
-"README.md": "..."
-},
-"entrypoint": "src/bin/main.rs"
+
+var tools atomic.Pointer[aiTools]
+
+func configureAITools(conf *config.Config) {
+	previous := tools.Load()
+
+	generationCache := cache.New(conf.Cache)
+	if previous != nil && previous.conf.Cache == conf.Cache {
+		generationCache = previous.generationCache
+	}
+
+	// The prompts were loaded when the configuration was validated, failing
+	// here means the directory changed since. Generations fail until it is
+	// fixed rather than taking the process down.
+	library, err := prompts.Load(conf.Prompts.Directory)
+	if err != nil && previous != nil && previous.prompts != nil {
+		util.HandleError("Could not reload prompts, keeping the previous ones: %v", err, level.ERROR)
+		library = previous.prompts
+	} else {
+		util.HandleError("Could not load prompts: %v", err, level.ERROR)
+	}
+
+	specs, err := coverage.Load(conf.Coverage.Directory)
+	if err != nil {
+		util.HandleError("Could not load API specifications, coverage is not checked: %v", err, level.ERROR)
+		specs = &coverage.Library{}
+		if previous != nil {
+			specs = previous.specs
+		}
+	}
+
+	profiles := map[string]*modelProfile{}
+	for name, model := range conf.Vertex.ModelProfiles() {
+		profiles[name] = newModelProfile(conf, name, model)
+	}
+
+	tools.Store(&aiTools{
+		conf:            conf,
+		profiles:        profiles,
+		prompts:         library,
+		specs:           specs,
+		generationCache: generationCache,
+	})
 }
-Requirements:
-- HTTP client: reqwest with rustls-tls
-- Cargo.toml dependencies: reqwest, tokio, serde, serde_json, base64, sha2, ring (for signatures)
-- main.rs: Async main with tokio runtime, example usage
-- lib.rs: Re-export client and auth modules
-- client.rs: ApiClient struct with all endpoints
-- auth.rs: AuthManager for tokens, signature generation
-- Use async/await throughout
-</RUST>
-</LANGUAGE_TEMPLATES>
-<AUTHENTICATION_RULES>
-<TOKEN_FLOWS>
-Implement both token types based on API requirements:
-1. APPLICATION ACCESS TOKEN (mTLS only - no signature):
-- Endpoint: POST /oauth2/token
-- Body: grant_type=client_credentials&client_id=e77d776b-90af-4684-bebc-521e5b2614dd
-- Headers: Content-Type: application/x-www-form-urlencoded
-- Use: example_client_tls.cer/key for mTLS
-- No signature required for this request
-- Cache token (expires in 900 seconds)
-2. CUSTOMER ACCESS TOKEN (for AIS/CAF only - requires HTTP Signature):
-- Step 1: Get authorization code via browser redirect to:
-https://myaccount.sandbox.ing.com/authorize/v2/NL?client_id=e77d776b-90af-4684-bebc-521e5b2614dd&scope={SCOPES}&state={RANDOM}&redirect_uri={REDIRECT_URI}&response_type=code
-- Step 2: Exchange code for token at POST /oauth2/token
-- Body: grant_type=authorization_code&code={CODE}&redirect_uri={REDIRECT_URI}
-- Requires: HTTP Signature header with application access token
-- Returns: customer access token + refresh token
-RULES:
-- Payment Initiation API: Use APPLICATION token only
-- Account Information API: Use CUSTOMER token
-- Confirmation of Funds API: Use CUSTOMER token
-- Showcase API: Use APPLICATION token only
-- Real-time Account Reporting API: Use APPLICATION token only
-</TOKEN_FLOWS>
-<SIGNATURE_PROTOCOLS>
-Different APIs require different signatures:
-X-JWS-SIGNATURE (Payment Initiation API):
-- Header: x-jws-signature
-- Format: {base64url(JWS_protected_header)}..{base64url(signature_value)}
-- Sign: (request-target), digest, content-type
-- Algorithm: PS256 (RSA-PSS with SHA-256)
-- Certificate: Include TPP-Signature-Certificate header
-- Implementation: Use crypto libraries (jose, python-jose, jsonwebtoken, etc.)
-HTTP SIGNATURE (Account Information, CAF APIs):
-- Header: Signature
-- Format: keyId="{CLIENT_ID}",algorithm="rsa-sha256",headers="(request-target) date digest",signature="{base64_signature}"
-- Sign: (request-target), date, digest
-- Date: Must be within ±3 minutes of current time (RFC 7231 format)
-- Digest: SHA-256 hash of body, base64 encoded
-- Implementation: Use crypto libraries`
 
-func configureAITools() {
+func newModelProfile(conf *config.Config, name string, model config.AIModelConfig) *modelProfile {
 	dataStorePath := fmt.Sprintf(
 		"projects/%s/locations/%s/collections/default_collection/dataStores/%s",
 		conf.Vertex.Project.Id,
@@ -234,25 +124,16 @@ func configureAITools() {
 		},
 	}
 
-	systemInstructions := &genai.Content{
-		Parts: []*genai.Part{
-			{
-				Text: systemPrompt,
-			},
-		},
+	groundedSearchConfig := &genai.GenerateContentConfig{
+		Tools:           []*genai.Tool{searchTool},
+		Temperature:     &model.Temperature,
+		MaxOutputTokens: model.MaxOutputTokens,
+		CandidateCount:  1,
 	}
 
-	groundedSearchConfig = &genai.GenerateContentConfig{
-		SystemInstruction: systemInstructions,
-		Tools:             []*genai.Tool{searchTool},
-		Temperature:       &conf.Vertex.Model.Temperature,
-		MaxOutputTokens:   conf.Vertex.Model.MaxOutputTokens,
-		CandidateCount:    1,
-	}
-
-	formattingConfig = &genai.GenerateContentConfig{
-		Temperature:      &conf.Vertex.Model.Temperature,
-		MaxOutputTokens:  conf.Vertex.Model.MaxOutputTokens,
+	formattingConfig := &genai.GenerateContentConfig{
+		Temperature:      &model.Temperature,
+		MaxOutputTokens:  model.MaxOutputTokens,
 		ResponseMIMEType: "application/json",
 		ResponseSchema: &genai.Schema{
 			Type: genai.TypeObject,
@@ -271,4 +152,26 @@ func configureAITools() {
 			},
 		},
 	}
+
+	return &modelProfile{
+		name:                 name,
+		model:                model,
+		groundedSearchConfig: groundedSearchConfig,
+		formattingConfig:     formattingConfig,
+	}
+}
+
+// withSystemPrompt returns a copy of the profile's grounded search config
+// using systemPrompt as system instruction.
+func (p *modelProfile) withSystemPrompt(systemPrompt string) *genai.GenerateContentConfig {
+	generationConfig := *p.groundedSearchConfig
+	generationConfig.SystemInstruction = &genai.Content{
+		Parts: []*genai.Part{
+			{
+				Text: systemPrompt,
+			},
+		},
+	}
+
+	return &generationConfig
 }
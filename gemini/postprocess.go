@@ -0,0 +1,158 @@
+package gemini
+
+import (
+	"ai-test/config"
+	"ai-test/fileset"
+	"ai-test/metrics"
+	"ai-test/postprocess"
+	"ai-test/secrets"
+	"ai-test/server/responses"
+	"ai-test/syntax"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+type postProcessingStep struct {
+	name string
+	run  func(ctx context.Context, t *aiTools, response *responses.GenerationResponse)
+}
+
+// postProcessing runs in order on every generated response before it is
+// cached, so the API, the chat seed and the archive all see the same files.
+var postProcessing = []postProcessingStep{
+	{"unescape", unescapeFiles},
+	{"normalize", normalizeFiles},
+	{"format", formatFiles},
+	{"syntax_check", checkFiles},
+}
+
+func postProcess(ctx context.Context, t *aiTools, response *responses.GenerationResponse) {
+	for _, step := range postProcessing {
+		start := time.Now()
+		step.run(ctx, t, response)
+		metrics.ObserveStep(step.name, time.Since(start))
+	}
+}
+
+// unescapeFiles decodes the HTML entities the model escaped the files with.
+func unescapeFiles(_ context.Context, _ *aiTools, response *responses.GenerationResponse) {
+	for i := range response.Files {
+		file := &response.Files[i]
+
+		code, changed := postprocess.Unescape(file.FilePath, file.Code)
+		if changed {
+			log.Debugf("Unescaped HTML entities in %s", file.FilePath)
+			file.Code = code
+		}
+	}
+}
+
+// normalizeFiles canonicalizes the generated paths and drops the files that
+// are unsafe to write or exceed the configured limits, reporting why.
+func normalizeFiles(_ context.Context, t *aiTools, response *responses.GenerationResponse) {
+	conf := t.conf.Files
+
+	entries := make([]fileset.Entry, len(response.Files))
+	for i, file := range response.Files {
+		entries[i] = fileset.Entry{Path: file.FilePath, Size: len(file.Code)}
+	}
+
+	paths, violations := fileset.Normalize(entries, fileset.Limits{
+		MaxFileSize:  conf.MaxFileSize,
+		MaxTotalSize: conf.MaxTotalSize,
+	})
+
+	kept := response.Files[:0]
+	for i, file := range response.Files {
+		if paths[i] == "" {
+			continue
+		}
+		file.FilePath = paths[i]
+		kept = append(kept, file)
+	}
+
+	for _, violation := range violations {
+		log.Warnf("Dropped generated file %q: %s", violation.Path, violation.Message)
+	}
+
+	response.Files = kept
+	response.Violations = append(response.Violations, violations...)
+}
+
+// formatFiles formats every file with the formatter of its language, in
+// parallel. A file failing to format is kept as generated and the failure is
+// reported on it.
+func formatFiles(ctx context.Context, t *aiTools, response *responses.GenerationResponse) {
+	conf := t.conf.Formatting
+	if !conf.Enabled {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range response.Files {
+		file := &response.Files[i]
+
+		wg.Go(func() {
+			ctx, cancel := withTimeout(ctx, conf.Timeout)
+			defer cancel()
+
+			file.Code, file.Formatting = postprocess.Format(ctx, file.FilePath, file.Code)
+			if file.Formatting != nil && file.Formatting.Error != "" {
+				log.Warnf("Could not format %s with %s: %s", file.FilePath, file.Formatting.Formatter, file.Formatting.Error)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// checkFiles attaches the syntax errors of every file to it, in parallel.
+func checkFiles(ctx context.Context, t *aiTools, response *responses.GenerationResponse) {
+	conf := t.conf.Syntax
+	if !conf.Enabled {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range response.Files {
+		file := &response.Files[i]
+
+		wg.Go(func() {
+			ctx, cancel := withTimeout(ctx, conf.Timeout)
+			defer cancel()
+
+			file.Diagnostics = syntax.Check(ctx, file.FilePath, file.Code)
+			if len(file.Diagnostics) > 0 {
+				log.Warnf("Found %d syntax error(s) in %s", len(file.Diagnostics), file.FilePath)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// scanSecrets attaches the suspected secrets found in every file to it and
+// redacts them when the request asks to. The client id the code was
+// generated for is not a secret and is always allowed.
+func scanSecrets(response *responses.GenerationResponse, conf config.SecretsConfig, request GenerationRequest) {
+	scanner := secrets.NewScanner(append([]string{request.Identity().ClientId}, conf.Allowed...)...)
+	redact := request.RedactSecrets(conf)
+
+	response.SecretsFound = 0
+	for i := range response.Files {
+		file := &response.Files[i]
+
+		file.Secrets = scanner.Scan(file.Code)
+		if len(file.Secrets) == 0 {
+			continue
+		}
+
+		log.Warnf("Found %d suspected secret(s) in %s", len(file.Secrets), file.FilePath)
+		response.SecretsFound += len(file.Secrets)
+
+		if redact {
+			file.Code = secrets.Redact(file.Code, file.Secrets)
+		}
+	}
+}
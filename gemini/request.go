@@ -0,0 +1,138 @@
+package gemini
+
+import (
+	"ai-test/cache"
+	"ai-test/config"
+	"ai-test/identity"
+	"ai-test/languages"
+	"fmt"
+	"strings"
+)
+
+// GenerationRequest holds the structured parameters of a code generation.
+// Prompt is optional free text; when empty it is built from Language and Api.
+type GenerationRequest struct {
+	Prompt        string `query:"prompt"`
+	Language      string `query:"language"`
+	Api           string `query:"api"`
+	Profile       string `query:"profile"`
+	PromptVersion string `query:"promptVersion"`
+	Cache         string `query:"cache"`
+	Secrets       string `query:"secrets"`
+	Coverage      string `query:"coverage"`
+	JobId         string `query:"jobId"`
+
+	// Client identity the generated code authenticates with, see
+	// identity.ClientIdentity for the defaults.
+	Environment     string `query:"environment"`
+	ClientId        string `query:"clientId"`
+	CertificatePath string `query:"certificatePath"`
+	KeyPath         string `query:"keyPath"`
+	Host            string `query:"host"`
+	AuthorizeHost   string `query:"authorizeHost"`
+}
+
+const bypassCache = "bypass"
+
+func (r GenerationRequest) BypassCache() bool {
+	return strings.EqualFold(strings.TrimSpace(r.Cache), bypassCache)
+}
+
+const redactSecrets = "redact"
+
+// RedactSecrets reports whether suspected secrets are replaced in the
+// returned files, either because the request asks for it or the
+// configuration always does.
+func (r GenerationRequest) RedactSecrets(conf config.SecretsConfig) bool {
+	return ShouldRedact(conf, r.Secrets)
+}
+
+// ShouldRedact reports whether suspected secrets are replaced given the
+// secrets parameter of a request, which asks for it with "redact".
+func ShouldRedact(conf config.SecretsConfig, requested string) bool {
+	return conf.Redact || strings.EqualFold(strings.TrimSpace(requested), redactSecrets)
+}
+
+// Normalized returns a copy with casing and whitespace folded, so requests
+// that only differ cosmetically share the same cache entry.
+func (r GenerationRequest) Normalized() GenerationRequest {
+	id := r.Identity()
+
+	return GenerationRequest{
+		Prompt:          strings.Join(strings.Fields(r.Prompt), " "),
+		Language:        strings.ToLower(strings.TrimSpace(r.Language)),
+		Api:             strings.ToLower(strings.Join(strings.Fields(r.Api), " ")),
+		Environment:     id.Environment,
+		ClientId:        id.ClientId,
+		CertificatePath: id.CertificatePath,
+		KeyPath:         id.KeyPath,
+		Host:            id.Host,
+		AuthorizeHost:   id.AuthorizeHost,
+	}
+}
+
+// Identity returns the requested client identity with defaults applied.
+func (r GenerationRequest) Identity() identity.ClientIdentity {
+	return identity.ClientIdentity{
+		Environment:     r.Environment,
+		ClientId:        r.ClientId,
+		CertificatePath: r.CertificatePath,
+		KeyPath:         r.KeyPath,
+		Host:            r.Host,
+		AuthorizeHost:   r.AuthorizeHost,
+	}.Resolved()
+}
+
+func (r GenerationRequest) BuildPrompt() string {
+	if strings.TrimSpace(r.Prompt) != "" {
+		return r.Prompt
+	}
+	return fmt.Sprintf("Build me a fully working %s application that calls %s", r.Language, r.Api)
+}
+
+const repromptMissing = "reprompt"
+
+// RepromptMissing reports whether the model is prompted again for the
+// endpoints of the API the generated client does not cover.
+func (r GenerationRequest) RepromptMissing(conf config.CoverageConfig) bool {
+	return conf.Reprompt || strings.EqualFold(strings.TrimSpace(r.Coverage), repromptMissing)
+}
+
+// promptData is what system prompt templates are rendered with.
+type promptData struct {
+	Request           GenerationRequest
+	Identity          identity.ClientIdentity
+	LanguageTemplates string
+}
+
+func (r GenerationRequest) promptData() promptData {
+	// Only the selected language's template is needed; a free-form prompt
+	// may ask for any of them.
+	selected := languages.All()
+	if language, ok := languages.Get(r.Language); ok {
+		selected = []languages.Language{language}
+	}
+
+	return promptData{
+		Request:           r.Normalized(),
+		Identity:          r.Identity(),
+		LanguageTemplates: languages.PromptSection(selected...),
+	}
+}
+
+// cacheKey identifies a generation by the request and every setting that
+// shapes its response, so a configuration reload never serves responses
+// built under the previous settings.
+func (r GenerationRequest) cacheKey(conf *config.Config, model config.AIModelConfig, promptVersion string) string {
+	return cache.Key(
+		r.Normalized(),
+		model,
+		conf.Vertex.DataStore,
+		promptVersion,
+		r.RepromptMissing(conf.Coverage),
+		conf.Coverage.Directory,
+		conf.Files,
+		conf.Formatting.Enabled,
+		conf.Syntax.Enabled,
+	)
+}
@@ -0,0 +1,162 @@
+package gemini
+
+import (
+	"ai-test/config"
+	"ai-test/health"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+	"google.golang.org/genai"
+)
+
+var breaker = newCircuitBreaker()
+
+// A server whose breaker is open rejects every call until OpenTimeout has
+// passed. After that the next request is the trial that can close it again,
+// so the server has to stay ready to receive it.
+func init() {
+	health.Register(health.Func("circuit_breaker", func(context.Context) error {
+		snapshot := breaker.snapshot()
+		if snapshot.State != BreakerOpen || snapshot.OpenedAt == nil {
+			return nil
+		}
+
+		timeout := config.Get().Vertex.CircuitBreaker.OpenTimeout
+		if remaining := timeout - time.Since(*snapshot.OpenedAt); remaining > 0 {
+			return fmt.Errorf("circuit breaker is open for another %s", remaining.Round(time.Second))
+		}
+		return nil
+	}))
+}
+
+// Breaker returns the current state of the circuit breaker guarding the model.
+func Breaker() BreakerSnapshot {
+	return breaker.snapshot()
+}
+
+// callModel runs a provider call through the circuit breaker and retries it
+// according to the configured retry policy.
+func callModel[T any](ctx context.Context, conf config.VertexAIConfig, call func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	policy := conf.Retry
+	attempts := max(policy.MaxAttempts, 1)
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		trial, ok := breaker.allow(conf.CircuitBreaker)
+		if !ok {
+			return zero, newError(CircuitOpen, "circuit breaker is open")
+		}
+
+		var result T
+		result, err = guardedCall(ctx, conf, trial, call)
+		if err == nil {
+			recordUsage(ctx, result)
+			return result, nil
+		}
+
+		if !retryable(policy, err) {
+			return zero, err
+		}
+
+		if attempt == attempts {
+			break
+		}
+
+		wait := backoff(policy, attempt)
+		log.Warnf("Model call failed (attempt %d/%d), retrying in %v: %v", attempt, attempts, wait, err)
+
+		select {
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+
+	return zero, err
+}
+
+// guardedCall makes one call and reports its outcome to the breaker. Only a
+// success or a retryable upstream failure says something about the upstream;
+// a trial call ending any other way, a panic included, reopens the breaker.
+func guardedCall[T any](ctx context.Context, conf config.VertexAIConfig, trial bool, call func(ctx context.Context) (T, error)) (T, error) {
+	settled := false
+	if trial {
+		defer func() {
+			if !settled {
+				breaker.abandon()
+			}
+		}()
+	}
+
+	result, err := call(ctx)
+	switch {
+	case err == nil:
+		breaker.success()
+		settled = true
+	case retryable(conf.Retry, err):
+		breaker.failure(conf.CircuitBreaker)
+		settled = true
+	}
+
+	return result, err
+}
+
+// retryable reports whether err belongs to one of the configured status
+// classes: an exact code ("503"), a class ("5xx") or "network" for errors
+// that never got an HTTP response.
+func retryable(policy config.RetryConfig, err error) bool {
+	if stderrors.Is(err, context.Canceled) || stderrors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var gerr *Error
+	if stderrors.As(err, &gerr) {
+		return false
+	}
+
+	status := "network"
+	var apiErr genai.APIError
+	if stderrors.As(err, &apiErr) {
+		status = strconv.Itoa(apiErr.Code)
+	}
+
+	for _, class := range policy.RetryableStatuses {
+		class = strings.ToLower(strings.TrimSpace(class))
+
+		if class == status {
+			return true
+		}
+
+		if len(class) == 3 && strings.HasSuffix(class, "xx") && len(status) == 3 && class[0] == status[0] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func backoff(policy config.RetryConfig, attempt int) time.Duration {
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	wait := float64(policy.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if policy.MaxBackoff > 0 {
+		wait = math.Min(wait, float64(policy.MaxBackoff))
+	}
+
+	if policy.Jitter > 0 {
+		wait += wait * policy.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(wait)
+}
@@ -0,0 +1,126 @@
+package gemini
+
+import (
+	"ai-test/auth"
+	"ai-test/config"
+	"ai-test/jobs"
+	"ai-test/server/responses"
+	"ai-test/store"
+	"ai-test/util"
+	"ai-test/util/level"
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// jobStatusTtl bounds how long the status of a job can be polled after it
+// ended.
+const jobStatusTtl = time.Hour
+
+// The state of a user lives in the shared store rather than in the client, so
+// every prefork child serves the same status, generation and chat.
+
+// Generation is the last code generation of a user. Chats continue from it
+// and archives are built from it.
+type Generation struct {
+	Prompt   string                        `json:"prompt"`
+	Response *responses.GenerationResponse `json:"response"`
+}
+
+type chatSession struct {
+	Profile string           `json:"profile"`
+	History []*genai.Content `json:"history"`
+}
+
+func userOf(ctx context.Context) string {
+	user, ok := auth.FromContext(ctx)
+	if !ok {
+		user = auth.User{Id: "unknown", Method: auth.MethodAnonymous}
+	}
+	return user.String()
+}
+
+func statusKey(user string) string {
+	return "status:" + user
+}
+
+func jobStatusKey(user string, job string) string {
+	return "status:" + user + ":" + job
+}
+
+func generationKey(user string) string {
+	return "generation:" + user
+}
+
+func chatKey(user string) string {
+	return "chat:" + user
+}
+
+// Status returns the status of the generation job of user, or of the last
+// generation of user without a job.
+func Status(user auth.User, job string) (responses.GenerationStatus, error) {
+	key := statusKey(user.String())
+	if job != "" {
+		key = jobStatusKey(user.String(), job)
+	}
+
+	data, err := store.Shared().Get(key)
+	if errors.Is(err, store.ErrNotFound) {
+		return responses.NotStarted, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return responses.GenerationStatus(data), nil
+}
+
+func setStatus(ctx context.Context, status responses.GenerationStatus) {
+	timeStage(ctx, status)
+
+	shared := store.Shared()
+	user := userOf(ctx)
+
+	err := shared.Set(statusKey(user), []byte(status), config.Get().Store.Retention)
+	util.HandleError("Could not save generation status: %v", err, level.ERROR)
+
+	if job, ok := jobs.FromContext(ctx); ok {
+		err := shared.Set(jobStatusKey(user, job.Id), []byte(status), jobStatusTtl)
+		util.HandleError("Could not save generation status: %v", err, level.ERROR)
+	}
+}
+
+// LastGeneration returns the last generation of user, store.ErrNotFound when
+// there is none.
+func LastGeneration(user auth.User) (*Generation, error) {
+	return lastGeneration(user.String())
+}
+
+func lastGeneration(user string) (*Generation, error) {
+	generation := &Generation{}
+	if err := store.GetJSON(store.Shared(), generationKey(user), generation); err != nil {
+		return nil, err
+	}
+	return generation, nil
+}
+
+func saveGeneration(ctx context.Context, prompt string, response *responses.GenerationResponse) {
+	err := store.SetJSON(store.Shared(), generationKey(userOf(ctx)), Generation{
+		Prompt:   prompt,
+		Response: response,
+	}, config.Get().Store.Retention)
+	util.HandleError("Could not save generation: %v", err, level.ERROR)
+}
+
+func loadChat(ctx context.Context) (*chatSession, error) {
+	session := &chatSession{}
+	if err := store.GetJSON(store.Shared(), chatKey(userOf(ctx)), session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func saveChat(ctx context.Context, session chatSession) error {
+	return store.SetJSON(store.Shared(), chatKey(userOf(ctx)), session, config.Get().Store.Retention)
+}
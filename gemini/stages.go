@@ -0,0 +1,51 @@
+package gemini
+
+import (
+	"ai-test/metrics"
+	"ai-test/server/responses"
+	"context"
+	"sync"
+	"time"
+)
+
+type stagesKey struct{}
+
+// stageTimer times the statuses a generation passes through, each status
+// lasting until the next one is set.
+type stageTimer struct {
+	mu      sync.Mutex
+	stage   responses.GenerationStatus
+	entered time.Time
+}
+
+func withStageTimer(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stagesKey{}, &stageTimer{})
+}
+
+// enter ends the current stage, if any, and starts the given one. Done and
+// NotStarted end the generation.
+func (s *stageTimer) enter(stage responses.GenerationStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.stage != "" && s.stage != stage {
+		metrics.ObserveStage(string(s.stage), now.Sub(s.entered))
+	}
+
+	if stage == responses.Done || stage == responses.NotStarted {
+		s.stage = ""
+		return
+	}
+
+	if stage != s.stage {
+		s.stage = stage
+		s.entered = now
+	}
+}
+
+func timeStage(ctx context.Context, stage responses.GenerationStatus) {
+	if timer, ok := ctx.Value(stagesKey{}).(*stageTimer); ok {
+		timer.enter(stage)
+	}
+}
@@ -0,0 +1,22 @@
+package gemini
+
+import (
+	"ai-test/metrics"
+	"ai-test/quota"
+	"context"
+
+	"google.golang.org/genai"
+)
+
+// recordUsage charges the tokens of a successful model call to the quota of
+// the user it was made for and counts them in the metrics.
+func recordUsage(ctx context.Context, result any) {
+	response, ok := result.(*genai.GenerateContentResponse)
+	if !ok || response == nil || response.UsageMetadata == nil {
+		return
+	}
+
+	usage := response.UsageMetadata
+	metrics.Tokens(response.ModelVersion, usage.PromptTokenCount, usage.CandidatesTokenCount, usage.ThoughtsTokenCount)
+	quota.Consume(ctx, int64(usage.TotalTokenCount))
+}
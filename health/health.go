@@ -0,0 +1,106 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// checkTimeout bounds each readiness check, a probe must answer quickly.
+const checkTimeout = 2 * time.Second
+
+// Checker is a readiness check contributed by a subsystem. Check returns nil
+// when the subsystem is able to serve requests.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type funcChecker struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (f funcChecker) Name() string {
+	return f.name
+}
+
+func (f funcChecker) Check(ctx context.Context) error {
+	return f.check(ctx)
+}
+
+// Func turns a function into a Checker.
+func Func(name string, check func(ctx context.Context) error) Checker {
+	return funcChecker{name: name, check: check}
+}
+
+var (
+	mu       sync.RWMutex
+	checkers []Checker
+)
+
+// Register adds a readiness check. Subsystems register theirs from init.
+func Register(checker Checker) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	checkers = append(checkers, checker)
+}
+
+type Result struct {
+	Name     string        `json:"name"`
+	Ready    bool          `json:"ready"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Check runs every registered check in parallel and reports whether all of
+// them passed, with the results in registration order.
+func Check(ctx context.Context) (bool, []Result) {
+	mu.RLock()
+	registered := append([]Checker(nil), checkers...)
+	mu.RUnlock()
+
+	results := make([]Result, len(registered))
+
+	var wg sync.WaitGroup
+	for i, checker := range registered {
+		wg.Go(func() {
+			results[i] = run(ctx, checker)
+		})
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range results {
+		ready = ready && result.Ready
+	}
+	return ready, results
+}
+
+func run(ctx context.Context, checker Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	result := Result{Name: checker.Name(), Ready: true}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		result.Ready = false
+		result.Error = err.Error()
+	}
+	result.Duration = time.Since(start)
+	return result
+}
@@ -0,0 +1,124 @@
+package identity
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	Sandbox    = "sandbox"
+	Production = "production"
+
+	// SandboxClientId is the client registered by ING for the public sandbox.
+	SandboxClientId = "e77d776b-90af-4684-bebc-521e5b2614dd"
+
+	DefaultCertificatePath = "src/certs/example_client_tls.cer"
+	DefaultKeyPath         = "src/certs/example_client_tls.key"
+)
+
+var (
+	apiHosts = map[string]string{
+		Sandbox:    "api.sandbox.ing.com",
+		Production: "api.ing.com",
+	}
+
+	authorizeHosts = map[string]string{
+		Sandbox:    "myaccount.sandbox.ing.com",
+		Production: "myaccount.ing.com",
+	}
+
+	hostPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+	// Paths are rendered into the system prompt and the generated project,
+	// anything beyond plain file names would let a caller inject text there.
+	pathPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+(/[A-Za-z0-9_.-]+)*$`)
+)
+
+const maxPathLength = 255
+
+// ClientIdentity is the app registration a generated client authenticates
+// with. Empty fields fall back to the defaults of the environment, which
+// for the sandbox are ING's public example client and certificates.
+type ClientIdentity struct {
+	Environment     string `json:"environment"`
+	ClientId        string `json:"clientId"`
+	CertificatePath string `json:"certificatePath"`
+	KeyPath         string `json:"keyPath"`
+	Host            string `json:"host"`
+	AuthorizeHost   string `json:"authorizeHost"`
+}
+
+// Resolved returns a copy with the environment defaults filled in.
+func (i ClientIdentity) Resolved() ClientIdentity {
+	environment := strings.ToLower(cmp.Or(strings.TrimSpace(i.Environment), Sandbox))
+
+	defaultClientId := ""
+	if environment == Sandbox {
+		defaultClientId = SandboxClientId
+	}
+
+	return ClientIdentity{
+		Environment:     environment,
+		ClientId:        strings.ToLower(cmp.Or(strings.TrimSpace(i.ClientId), defaultClientId)),
+		CertificatePath: cmp.Or(cleanPath(i.CertificatePath), DefaultCertificatePath),
+		KeyPath:         cmp.Or(cleanPath(i.KeyPath), DefaultKeyPath),
+		Host:            strings.ToLower(cmp.Or(strings.TrimSpace(i.Host), apiHosts[environment])),
+		AuthorizeHost:   strings.ToLower(cmp.Or(strings.TrimSpace(i.AuthorizeHost), authorizeHosts[environment])),
+	}
+}
+
+// Validate checks a resolved identity and returns every problem found.
+func (i ClientIdentity) Validate() []string {
+	var problems []string
+
+	if _, ok := apiHosts[i.Environment]; !ok {
+		problems = append(problems, fmt.Sprintf("environment must be %s or %s", Sandbox, Production))
+	}
+
+	if i.ClientId == "" {
+		problems = append(problems, "clientId is required outside the sandbox")
+	} else if _, err := uuid.Parse(i.ClientId); err != nil {
+		problems = append(problems, "clientId must be a UUID")
+	}
+
+	if !relative(i.CertificatePath) {
+		problems = append(problems, "certificatePath must be relative to the project root")
+	} else if !safePath(i.CertificatePath) {
+		problems = append(problems, "certificatePath may only contain letters, digits, '.', '_', '-' and '/'")
+	}
+	if !relative(i.KeyPath) {
+		problems = append(problems, "keyPath must be relative to the project root")
+	} else if !safePath(i.KeyPath) {
+		problems = append(problems, "keyPath may only contain letters, digits, '.', '_', '-' and '/'")
+	}
+
+	if !hostPattern.MatchString(i.Host) {
+		problems = append(problems, "host must be a host name")
+	}
+	if !hostPattern.MatchString(i.AuthorizeHost) {
+		problems = append(problems, "authorizeHost must be a host name")
+	}
+
+	return problems
+}
+
+func relative(p string) bool {
+	return !path.IsAbs(p) && p != ".." && !strings.HasPrefix(p, "../")
+}
+
+func safePath(p string) bool {
+	return len(p) <= maxPathLength && pathPattern.MatchString(p)
+}
+
+func cleanPath(p string) string {
+	p = strings.TrimSpace(strings.ReplaceAll(p, "\\", "/"))
+	if p == "" {
+		return ""
+	}
+	return strings.TrimPrefix(path.Clean(p), "./")
+}
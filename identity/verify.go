@@ -0,0 +1,83 @@
+package identity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Report tells whether the generated files use the requested identity.
+type Report struct {
+	ClientId        bool     `json:"clientId"`
+	Host            bool     `json:"host"`
+	CertificatePath bool     `json:"certificatePath"`
+	KeyPath         bool     `json:"keyPath"`
+	Problems        []string `json:"problems"`
+}
+
+func (r Report) Valid() bool {
+	return len(r.Problems) == 0
+}
+
+// Verify looks for the identity's values in the generated file contents and
+// flags values of another environment or registration leaking into them.
+func (i ClientIdentity) Verify(contents []string) Report {
+	all := strings.Join(contents, "\n")
+
+	report := Report{
+		ClientId:        strings.Contains(strings.ToLower(all), i.ClientId),
+		Host:            strings.Contains(all, i.Host),
+		CertificatePath: strings.Contains(all, i.CertificatePath),
+		KeyPath:         strings.Contains(all, i.KeyPath),
+		Problems:        []string{},
+	}
+
+	checks := []struct {
+		name  string
+		found bool
+	}{
+		{"client id " + i.ClientId, report.ClientId},
+		{"host " + i.Host, report.Host},
+		{"certificate path " + i.CertificatePath, report.CertificatePath},
+		{"key path " + i.KeyPath, report.KeyPath},
+	}
+
+	for _, check := range checks {
+		if !check.found {
+			report.Problems = append(report.Problems, check.name+" is not used by the generated code")
+		}
+	}
+
+	if i.ClientId != SandboxClientId && strings.Contains(strings.ToLower(all), SandboxClientId) {
+		report.Problems = append(report.Problems, "the sandbox example client id is used instead of "+i.ClientId)
+	}
+
+	for _, environment := range []string{Sandbox, Production} {
+		host := apiHosts[environment]
+		if environment != i.Environment && host != i.Host && containsHost(all, host) {
+			report.Problems = append(report.Problems, fmt.Sprintf("the %s host %s is used", environment, host))
+		}
+	}
+
+	return report
+}
+
+// containsHost reports whether host occurs in s as a whole host name, so
+// api.ing.com is not found inside api.sandbox.ing.com.
+func containsHost(s string, host string) bool {
+	for offset := 0; ; {
+		index := strings.Index(s[offset:], host)
+		if index < 0 {
+			return false
+		}
+
+		start := offset + index
+		if start == 0 || !isHostChar(s[start-1]) {
+			return true
+		}
+		offset = start + len(host)
+	}
+}
+
+func isHostChar(c byte) bool {
+	return c == '.' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,246 @@
+package jobs
+
+import (
+	"ai-test/health"
+	"ai-test/store"
+	"ai-test/util"
+	"ai-test/util/level"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// lease bounds how long a job is registered, should the process running
+	// it die before finishing it.
+	lease = time.Hour
+	// cancelPoll is how often a job checks whether another process asked to
+	// cancel it.
+	cancelPoll = time.Second
+)
+
+var (
+	ErrDuplicateJob = errors.New("a job with this id is already running")
+	ErrDraining     = errors.New("the server is shutting down")
+)
+
+type Job struct {
+	Id      string    `json:"id"`
+	Owner   string    `json:"owner"`
+	Started time.Time `json:"started"`
+	cancel  context.CancelFunc
+}
+
+// Jobs are registered in the shared store, so any process can cancel them.
+// The cancel functions of the jobs this process runs are kept here.
+var (
+	mu       sync.Mutex
+	running  = map[string]*Job{}
+	draining bool
+	// idle is closed once draining and no job is left.
+	idle = make(chan struct{})
+)
+
+// A draining server must get no new requests from the load balancer.
+func init() {
+	health.Register(health.Func("jobs", func(context.Context) error {
+		if Draining() {
+			return ErrDraining
+		}
+		return nil
+	}))
+}
+
+func jobKey(id string) string {
+	return "job:" + id
+}
+
+func cancelKey(id string) string {
+	return "job:" + id + ":cancel"
+}
+
+type contextKey struct{}
+
+// FromContext returns the job ctx belongs to, if any.
+func FromContext(ctx context.Context) (*Job, bool) {
+	job, ok := ctx.Value(contextKey{}).(*Job)
+	return job, ok
+}
+
+// Start registers a cancellable job of owner derived from parent. An empty id
+// gets a generated one. The returned finish function must be called once the
+// job is over; it releases the job's resources.
+func Start(parent context.Context, id string, owner string) (context.Context, *Job, func(), error) {
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	if Draining() {
+		return nil, nil, nil, ErrDraining
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	job := &Job{
+		Id:      id,
+		Owner:   owner,
+		Started: time.Now(),
+		cancel:  cancel,
+	}
+	ctx = context.WithValue(ctx, contextKey{}, job)
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		cancel()
+		return nil, nil, nil, err
+	}
+
+	shared := store.Shared()
+
+	added, err := shared.Add(jobKey(id), data, lease)
+	if err != nil {
+		cancel()
+		return nil, nil, nil, err
+	}
+	if !added {
+		cancel()
+		return nil, nil, nil, ErrDuplicateJob
+	}
+
+	// Draining may have begun while the job was registered.
+	mu.Lock()
+	if draining {
+		mu.Unlock()
+		cancel()
+		util.HandleError("Could not unregister job: %v", shared.Delete(jobKey(id)), level.ERROR)
+		return nil, nil, nil, ErrDraining
+	}
+	running[id] = job
+	mu.Unlock()
+
+	go watch(ctx, job)
+
+	finish := func() {
+		cancel()
+
+		util.HandleError("Could not unregister job: %v", shared.Delete(jobKey(id)), level.ERROR)
+		util.HandleError("Could not unregister job: %v", shared.Delete(cancelKey(id)), level.ERROR)
+
+		mu.Lock()
+		delete(running, id)
+		checkIdle()
+		mu.Unlock()
+	}
+
+	return ctx, job, finish, nil
+}
+
+// watch cancels job once another process asks for it, until the job is over.
+func watch(ctx context.Context, job *Job) {
+	ticker := time.NewTicker(cancelPoll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := store.Shared().Get(cancelKey(job.Id)); err == nil {
+				job.cancel()
+				return
+			}
+		}
+	}
+}
+
+// Cancel stops the job with the given id if allowed accepts its owner. It
+// reports false when no such job is running or it is not allowed. A job of
+// another process stops within a second.
+func Cancel(id string, allowed func(owner string) bool) (bool, error) {
+	shared := store.Shared()
+
+	job := Job{}
+	err := store.GetJSON(shared, jobKey(id), &job)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !allowed(job.Owner) {
+		return false, nil
+	}
+
+	mu.Lock()
+	local, ok := running[id]
+	mu.Unlock()
+
+	if ok {
+		local.cancel()
+		return true, nil
+	}
+
+	return true, shared.Set(cancelKey(id), []byte("1"), lease)
+}
+
+// Drain stops new jobs from starting and waits for the running ones of this
+// process to finish, until ctx is done. It returns how many were left.
+func Drain(ctx context.Context) int {
+	mu.Lock()
+	if !draining {
+		draining = true
+		checkIdle()
+	}
+	mu.Unlock()
+
+	select {
+	case <-idle:
+		return 0
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return len(running)
+}
+
+// Running returns how many jobs this process is running.
+func Running() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return len(running)
+}
+
+// Draining reports whether the process stopped starting jobs.
+func Draining() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return draining
+}
+
+// CancelAll stops every job this process is running.
+func CancelAll() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, job := range running {
+		job.cancel()
+	}
+}
+
+// checkIdle closes idle once draining is done, mu must be held.
+func checkIdle() {
+	if !draining || len(running) > 0 {
+		return
+	}
+
+	select {
+	case <-idle:
+	default:
+		close(idle)
+	}
+}
@@ -0,0 +1,28 @@
+package languages
+
+func init() {
+	Register(Language{
+		Id:      "go",
+		Name:    "Go",
+		Aliases: []string{"golang"},
+		Files: []string{
+			"src/main.go",
+			"src/client.go",
+			"src/auth.go",
+			"go.mod",
+			"src/README.md",
+		},
+		Entrypoint:   "src/main.go",
+		BuildCommand: "go build ./...",
+		CheckCommand: "go vet ./...",
+		Requirements: []string{
+			`HTTP client: Use "net/http" with "crypto/tls" for mTLS`,
+			`Module name: "ing-api-client"`,
+			"Import paths: Use relative imports within module",
+			"Error handling: Return errors with fmt.Errorf, log to console",
+			"main.go: Demonstrates calling 2-3 key endpoints",
+			"client.go: Implements all API endpoints",
+			"auth.go: Handles token acquisition (application + customer tokens)",
+		},
+	})
+}
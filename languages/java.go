@@ -0,0 +1,33 @@
+package languages
+
+func init() {
+	Register(Language{
+		Id:   "java",
+		Name: "Java",
+		Files: []string{
+			"src/main/java/com/ing/client/Main.java",
+			"src/main/java/com/ing/client/ApiClient.java",
+			"src/main/java/com/ing/client/AuthManager.java",
+			"src/main/java/com/ing/client/SignatureUtils.java",
+			"pom.xml",
+			"src/main/resources/README.md",
+		},
+		Entrypoint: "src/main/java/com/ing/client/Main.java",
+		Dependencies: []string{
+			"com.squareup.okhttp3:okhttp",
+			"org.json:json",
+			"commons-codec:commons-codec",
+		},
+		BuildCommand: "mvn -q package",
+		CheckCommand: "mvn -q compile",
+		Requirements: []string{
+			"HTTP client: OkHttp3 (com.squareup.okhttp3)",
+			"Java version: 11 or higher",
+			"Package: com.ing.client",
+			"Main.java: Entry point with example usage",
+			"ApiClient.java: All endpoint implementations",
+			"AuthManager.java: Token management (caching, refresh)",
+			"SignatureUtils.java: HTTP/JWS signature generation",
+		},
+	})
+}
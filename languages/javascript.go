@@ -0,0 +1,31 @@
+package languages
+
+func init() {
+	Register(Language{
+		Id:      "javascript",
+		Name:    "JavaScript",
+		Aliases: []string{"js"},
+		Files: []string{
+			"src/index.js",
+			"src/client.js",
+			"src/auth.js",
+			"src/types.js",
+			"package.json",
+			"README.md",
+		},
+		Entrypoint:   "src/index.js",
+		Dependencies: []string{"axios"},
+		BuildCommand: "npm install",
+		CheckCommand: "node --check src/index.js",
+		Requirements: []string{
+			"HTTP client: axios",
+			"Runtime: Node.js 18+",
+			`Modules: ES6 modules ("type": "module" in package.json)`,
+			"Crypto: use the built-in crypto module",
+			"index.js: Main entry with examples",
+			"client.js: ApiClient class with all endpoints",
+			"auth.js: Authentication and signature utilities",
+			"types.js: JSDoc typedefs for request/response types",
+		},
+	})
+}
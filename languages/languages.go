@@ -0,0 +1,164 @@
+package languages
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Language describes a target language of the generated clients. The prompt
+// section, the output validation and the frontend's language picker are all
+// derived from it, so supporting a new language only takes registering one.
+type Language struct {
+	Id           string   `json:"id"`
+	Name         string   `json:"name"`
+	Aliases      []string `json:"-"`
+	Files        []string `json:"files"`
+	Entrypoint   string   `json:"entrypoint"`
+	Dependencies []string `json:"dependencies"`
+	BuildCommand string   `json:"buildCommand"`
+	CheckCommand string   `json:"checkCommand"`
+	Requirements []string `json:"-"`
+}
+
+var (
+	mu       sync.RWMutex
+	registry []Language
+)
+
+// Register adds a language to the registry. It panics on duplicate names, as
+// registrations happen from init functions.
+func Register(language Language) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, name := range language.names() {
+		if _, ok := lookup(name); ok {
+			panic(fmt.Sprintf("languages: %q registered twice", name))
+		}
+	}
+
+	registry = append(registry, language)
+}
+
+// Get finds a language by id, name or alias, ignoring case.
+func Get(name string) (Language, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return lookup(name)
+}
+
+// All returns the registered languages in registration order.
+func All() []Language {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return slices.Clone(registry)
+}
+
+func lookup(name string) (Language, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	for _, language := range registry {
+		if slices.Contains(language.names(), name) {
+			return language, true
+		}
+	}
+
+	return Language{}, false
+}
+
+func (l Language) names() []string {
+	names := []string{strings.ToLower(l.Id), strings.ToLower(l.Name)}
+	for _, alias := range l.Aliases {
+		names = append(names, strings.ToLower(alias))
+	}
+	return names
+}
+
+func (l Language) tag() string {
+	return strings.ToUpper(l.Id)
+}
+
+// PromptSection renders the LANGUAGE_TEMPLATES section of the system prompt
+// for the given languages.
+func PromptSection(languages ...Language) string {
+	var b strings.Builder
+
+	b.WriteString("<LANGUAGE_TEMPLATES>\n")
+	for _, language := range languages {
+		language.writePrompt(&b)
+	}
+	b.WriteString("</LANGUAGE_TEMPLATES>")
+
+	return b.String()
+}
+
+func (l Language) writePrompt(b *strings.Builder) {
+	fmt.Fprintf(b, "<%s>\n", l.tag())
+	b.WriteString("MANDATORY FILE STRUCTURE (do not add or remove files):\n")
+	b.WriteString("{\n\"files\": {\n")
+	entries := make([]string, 0, len(l.Files))
+	for _, file := range l.Files {
+		entries = append(entries, fmt.Sprintf("%q: \"...\"", file))
+	}
+	b.WriteString(strings.Join(entries, ",\n"))
+	fmt.Fprintf(b, "\n},\n\"entrypoint\": %q\n}\n", l.Entrypoint)
+
+	b.WriteString("Requirements:\n")
+	for _, requirement := range l.Requirements {
+		fmt.Fprintf(b, "- %s\n", requirement)
+	}
+	if len(l.Dependencies) > 0 {
+		fmt.Fprintf(b, "- Required dependencies: %s\n", strings.Join(l.Dependencies, ", "))
+	}
+	if l.BuildCommand != "" {
+		fmt.Fprintf(b, "- Must build with: %s\n", l.BuildCommand)
+	}
+
+	fmt.Fprintf(b, "</%s>\n", l.tag())
+}
+
+// ManifestReport compares generated file paths with a language's manifest.
+type ManifestReport struct {
+	Language   string   `json:"language"`
+	Missing    []string `json:"missing"`
+	Unexpected []string `json:"unexpected"`
+	Entrypoint bool     `json:"entrypoint"`
+}
+
+func (r ManifestReport) Valid() bool {
+	return len(r.Missing) == 0 && len(r.Unexpected) == 0 && r.Entrypoint
+}
+
+// Validate checks that exactly the manifest files were generated.
+func (l Language) Validate(paths []string) ManifestReport {
+	generated := make([]string, 0, len(paths))
+	for _, p := range paths {
+		generated = append(generated, path.Clean(strings.ReplaceAll(p, "\\", "/")))
+	}
+
+	report := ManifestReport{
+		Language:   l.Id,
+		Missing:    []string{},
+		Unexpected: []string{},
+		Entrypoint: slices.Contains(generated, l.Entrypoint),
+	}
+
+	for _, file := range l.Files {
+		if !slices.Contains(generated, file) {
+			report.Missing = append(report.Missing, file)
+		}
+	}
+
+	for _, file := range generated {
+		if !slices.Contains(l.Files, file) {
+			report.Unexpected = append(report.Unexpected, file)
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,32 @@
+package languages
+
+func init() {
+	Register(Language{
+		Id:      "python",
+		Name:    "Python",
+		Aliases: []string{"py"},
+		Files: []string{
+			"src/ing_client/__init__.py",
+			"src/ing_client/client.py",
+			"src/ing_client/auth.py",
+			"src/ing_client/__main__.py",
+			"setup.py",
+			"requirements.txt",
+			"README.md",
+		},
+		Entrypoint:   "src/ing_client/__main__.py",
+		Dependencies: []string{"requests", "cryptography", "PyJWT"},
+		BuildCommand: "pip install -r requirements.txt",
+		CheckCommand: "python -m compileall -q src",
+		Requirements: []string{
+			"HTTP client: requests library",
+			"Python version: 3.8+",
+			"Package name: ing-client",
+			"requirements.txt must include every required dependency",
+			"__init__.py: Expose main classes",
+			"client.py: ApiClient class with all endpoints",
+			"auth.py: AuthManager for tokens, signature generation",
+			"__main__.py: Runnable example (python -m ing_client)",
+		},
+	})
+}
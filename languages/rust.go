@@ -0,0 +1,30 @@
+package languages
+
+func init() {
+	Register(Language{
+		Id:      "rust",
+		Name:    "Rust",
+		Aliases: []string{"rs"},
+		Files: []string{
+			"src/bin/main.rs",
+			"src/lib.rs",
+			"src/client.rs",
+			"src/auth.rs",
+			"Cargo.toml",
+			"README.md",
+		},
+		Entrypoint:   "src/bin/main.rs",
+		Dependencies: []string{"reqwest", "tokio", "serde", "serde_json", "base64", "sha2", "ring"},
+		BuildCommand: "cargo build",
+		CheckCommand: "cargo check",
+		Requirements: []string{
+			"HTTP client: reqwest with rustls-tls",
+			"ring is used for signatures",
+			"main.rs: Async main with tokio runtime, example usage",
+			"lib.rs: Re-export client and auth modules",
+			"client.rs: ApiClient struct with all endpoints",
+			"auth.rs: AuthManager for tokens, signature generation",
+			"Use async/await throughout",
+		},
+	})
+}
@@ -0,0 +1,32 @@
+package languages
+
+func init() {
+	Register(Language{
+		Id:      "typescript",
+		Name:    "TypeScript",
+		Aliases: []string{"ts"},
+		Files: []string{
+			"src/index.ts",
+			"src/client.ts",
+			"src/auth.ts",
+			"src/types.ts",
+			"package.json",
+			"tsconfig.json",
+			"README.md",
+		},
+		Entrypoint:   "src/index.ts",
+		Dependencies: []string{"axios", "@types/node", "typescript", "ts-node"},
+		BuildCommand: "npm install && npx tsc",
+		CheckCommand: "npx tsc --noEmit",
+		Requirements: []string{
+			"HTTP client: axios",
+			"Runtime: Node.js 18+",
+			"Crypto: use the built-in crypto module",
+			"tsconfig.json: target ES2020, module commonjs, strict true",
+			"index.ts: Main entry with examples",
+			"client.ts: ApiClient class with all endpoints",
+			"auth.ts: Authentication and signature utilities",
+			"types.ts: TypeScript interfaces for request/response types",
+		},
+	})
+}
@@ -2,10 +2,17 @@ package main
 
 import (
 	"ai-test/config"
+	"ai-test/sandbox"
 	"ai-test/server"
 )
 
 func main() {
+	// Generated clients are run by a re-executed server, see sandbox.Run.
+	if sandbox.IsSupervisor() {
+		sandbox.Supervise()
+		return
+	}
+
 	config.ReadConfigFile()
-	server.StartServer(8080)
+	server.StartServer(config.Get().Server)
 }
@@ -0,0 +1,283 @@
+package metrics
+
+import (
+	"ai-test/store"
+	"ai-test/util"
+	"ai-test/util/level"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// publishInterval is how often prefork children share their metrics, a
+	// scrape sees them that much late at most.
+	publishInterval = 5 * time.Second
+	// snapshotStale is how old the snapshot of a child gets before the child
+	// counts as exited.
+	snapshotStale = 4 * publishInterval
+)
+
+var protoDelimited = expfmt.NewFormat(expfmt.TypeProtoDelim)
+
+// snapshot is what a prefork child shares of its metrics.
+type snapshot struct {
+	Metrics   []byte               `json:"metrics"`
+	Sessions  map[string]time.Time `json:"sessions"`
+	Published time.Time            `json:"published"`
+}
+
+func slotsKey(master int) string {
+	return fmt.Sprintf("metrics:%d:slots", master)
+}
+
+func snapshotKey(master int, slot int64) string {
+	return fmt.Sprintf("metrics:%d:%d", master, slot)
+}
+
+// Publish shares the metrics of a prefork child with its siblings until ctx
+// is done, any of them may serve the scrape.
+func Publish(ctx context.Context) {
+	if !fiber.IsChild() {
+		return
+	}
+
+	shared := store.Shared()
+	master := os.Getppid()
+
+	n, err := shared.Increment(slotsKey(master), 1, 0)
+	if err != nil {
+		util.HandleError("Could not publish metrics: %v", err, level.ERROR)
+		return
+	}
+
+	ticker := time.NewTicker(publishInterval)
+	defer ticker.Stop()
+
+	for {
+		own, err := local()
+		if err == nil {
+			// The last snapshot outlives the child until Forget, so its
+			// counters do not go down when it exits.
+			err = store.SetJSON(shared, snapshotKey(master, n), own, 0)
+		}
+		util.HandleError("Could not publish metrics: %v", err, level.WARN)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Forget removes what the children of this prefork master published.
+func Forget() {
+	shared := store.Shared()
+	master := os.Getpid()
+
+	slots, _ := shared.Counter(slotsKey(master))
+	for n := int64(1); n <= slots; n++ {
+		util.HandleError("Could not clean up metrics: %v", shared.Delete(snapshotKey(master, n)), level.WARN)
+	}
+	util.HandleError("Could not clean up metrics: %v", shared.Delete(slotsKey(master)), level.WARN)
+}
+
+func local() (snapshot, error) {
+	families, err := registry.Gather()
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	var buf bytes.Buffer
+	encoder := expfmt.NewEncoder(&buf, protoDelimited)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return snapshot{}, err
+		}
+	}
+
+	return snapshot{Metrics: buf.Bytes(), Sessions: sessions.active(), Published: time.Now()}, nil
+}
+
+// Gather returns the metrics of the whole server. A prefork child builds
+// them only from what every child published, itself included: mixing its live
+// counters with older ones of its siblings would let a counter go down from
+// one scrape to the next when they are served by different children.
+func Gather() ([]*dto.MetricFamily, error) {
+	if fiber.IsChild() {
+		return combine(published())
+	}
+
+	own, err := local()
+	if err != nil {
+		return nil, err
+	}
+	return combine([]snapshot{own})
+}
+
+// combine sums the metrics of the snapshots and counts the users with an
+// active session in any of them once. Stale snapshots are those of children
+// that exited: their counters and histograms are kept so the totals never go
+// down, their gauges are left out.
+func combine(snapshots []snapshot) ([]*dto.MetricFamily, error) {
+	merged := map[string]*dto.MetricFamily{}
+	active := map[string]bool{}
+
+	for _, s := range snapshots {
+		stale := time.Since(s.Published) > snapshotStale
+		decoder := expfmt.NewDecoder(bytes.NewReader(s.Metrics), protoDelimited)
+		for {
+			family := &dto.MetricFamily{}
+			err := decoder.Decode(family)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if stale && family.GetType() == dto.MetricType_GAUGE {
+				continue
+			}
+			merge(merged, family)
+		}
+
+		for user, last := range s.Sessions {
+			if time.Since(last) <= sessionWindow {
+				active[user] = true
+			}
+		}
+	}
+
+	merged["sessions"] = gauge(namespace+"_chat_active_sessions",
+		"Chat sessions with a message in the last "+sessionWindow.String()+".", float64(len(active)))
+
+	families := slices.Collect(maps.Values(merged))
+	slices.SortFunc(families, func(a, b *dto.MetricFamily) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+	return families, nil
+}
+
+// published returns the snapshots the children of this prefork master
+// published.
+func published() []snapshot {
+	shared := store.Shared()
+	master := os.Getppid()
+
+	slots, err := shared.Counter(slotsKey(master))
+	util.HandleError("Could not read published metrics: %v", err, level.WARN)
+
+	var snapshots []snapshot
+	for n := int64(1); n <= slots; n++ {
+		s := snapshot{}
+		err := store.GetJSON(shared, snapshotKey(master, n), &s)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			util.HandleError("Could not read published metrics: %v", err, level.WARN)
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots
+}
+
+// merge adds family to the merged ones, summing the series with the same
+// labels.
+func merge(merged map[string]*dto.MetricFamily, family *dto.MetricFamily) {
+	existing, ok := merged[family.GetName()]
+	if !ok {
+		merged[family.GetName()] = family
+		return
+	}
+
+	series := map[string]*dto.Metric{}
+	for _, metric := range existing.Metric {
+		series[labels(metric)] = metric
+	}
+
+	for _, metric := range family.Metric {
+		into, ok := series[labels(metric)]
+		if !ok {
+			existing.Metric = append(existing.Metric, metric)
+			continue
+		}
+
+		switch {
+		case metric.Counter != nil:
+			into.Counter.Value = proto.Float64(into.Counter.GetValue() + metric.Counter.GetValue())
+		case metric.Gauge != nil:
+			into.Gauge.Value = proto.Float64(into.Gauge.GetValue() + metric.Gauge.GetValue())
+		case metric.Histogram != nil:
+			addHistogram(into.Histogram, metric.Histogram)
+		}
+	}
+}
+
+// addHistogram sums histograms with the same buckets, as all of those of a
+// metric are.
+func addHistogram(into *dto.Histogram, h *dto.Histogram) {
+	into.SampleCount = proto.Uint64(into.GetSampleCount() + h.GetSampleCount())
+	into.SampleSum = proto.Float64(into.GetSampleSum() + h.GetSampleSum())
+
+	for i, bucket := range h.Bucket {
+		if i < len(into.Bucket) && into.Bucket[i].GetUpperBound() == bucket.GetUpperBound() {
+			into.Bucket[i].CumulativeCount = proto.Uint64(into.Bucket[i].GetCumulativeCount() + bucket.GetCumulativeCount())
+		}
+	}
+}
+
+func labels(metric *dto.Metric) string {
+	pairs := make([]string, 0, len(metric.Label))
+	for _, label := range metric.Label {
+		pairs = append(pairs, label.GetName()+"="+label.GetValue())
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func gauge(name string, help string, value float64) *dto.MetricFamily {
+	return &dto.MetricFamily{
+		Name: proto.String(name),
+		Help: proto.String(help),
+		Type: dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{
+			{Gauge: &dto.Gauge{Value: proto.Float64(value)}},
+		},
+	}
+}
+
+// Expose renders the metrics in the format the Accept header asks for.
+func Expose(accept string) ([]byte, string, error) {
+	families, err := Gather()
+	if err != nil {
+		return nil, "", err
+	}
+
+	format := expfmt.Negotiate(http.Header{"Accept": {accept}})
+
+	var buf bytes.Buffer
+	encoder := expfmt.NewEncoder(&buf, format)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return buf.Bytes(), string(format), nil
+}
@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// child is the registry of one prefork child.
+type child struct {
+	registry  *prometheus.Registry
+	requests  *prometheus.CounterVec
+	inflight  prometheus.Gauge
+	durations prometheus.Histogram
+}
+
+func newChild() *child {
+	c := &child{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "requests_total",
+			Help: "Requests.",
+		}, []string{"route"}),
+		inflight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "inflight",
+			Help: "Requests in flight.",
+		}),
+		durations: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "duration_seconds",
+			Help:    "Request durations.",
+			Buckets: []float64{1, 10},
+		}),
+	}
+	c.registry.MustRegister(c.requests, c.inflight, c.durations)
+	return c
+}
+
+func (c *child) snapshot(t *testing.T, sessions map[string]time.Time) snapshot {
+	t.Helper()
+
+	families, err := c.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	encoder := expfmt.NewEncoder(&buf, protoDelimited)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return snapshot{Metrics: buf.Bytes(), Sessions: sessions, Published: time.Now()}
+}
+
+func family(t *testing.T, families []*dto.MetricFamily, name string) *dto.MetricFamily {
+	t.Helper()
+
+	for _, f := range families {
+		if f.GetName() == name {
+			return f
+		}
+	}
+	t.Fatalf("expected a %s family", name)
+	return nil
+}
+
+func series(t *testing.T, f *dto.MetricFamily, route string) *dto.Metric {
+	t.Helper()
+
+	for _, metric := range f.Metric {
+		for _, label := range metric.Label {
+			if label.GetName() == "route" && label.GetValue() == route {
+				return metric
+			}
+		}
+	}
+	t.Fatalf("expected a %s series with route %q", f.GetName(), route)
+	return nil
+}
+
+func TestCombine(t *testing.T) {
+	first, second := newChild(), newChild()
+
+	first.requests.WithLabelValues("/generate").Add(3)
+	first.requests.WithLabelValues("/chat").Add(1)
+	second.requests.WithLabelValues("/generate").Add(2)
+	second.requests.WithLabelValues("/version").Add(5)
+
+	first.inflight.Set(2)
+	second.inflight.Set(1)
+
+	first.durations.Observe(0.5)
+	first.durations.Observe(5)
+	second.durations.Observe(20)
+
+	now := time.Now()
+	families, err := combine([]snapshot{
+		first.snapshot(t, map[string]time.Time{"alice": now, "bob": now.Add(-2 * sessionWindow)}),
+		second.snapshot(t, map[string]time.Time{"alice": now.Add(-time.Minute), "carol": now}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := family(t, families, "requests_total")
+	for route, want := range map[string]float64{"/generate": 5, "/chat": 1, "/version": 5} {
+		if got := series(t, requests, route).Counter.GetValue(); got != want {
+			t.Errorf("expected %v requests to %s, got %v", want, route, got)
+		}
+	}
+	if len(requests.Metric) != 3 {
+		t.Errorf("expected 3 request series, got %d", len(requests.Metric))
+	}
+
+	if got := family(t, families, "inflight").Metric[0].Gauge.GetValue(); got != 3 {
+		t.Errorf("expected 3 requests in flight, got %v", got)
+	}
+
+	histogram := family(t, families, "duration_seconds").Metric[0].Histogram
+	if histogram.GetSampleCount() != 3 || histogram.GetSampleSum() != 25.5 {
+		t.Errorf("expected 3 samples summing to 25.5, got %d summing to %v", histogram.GetSampleCount(), histogram.GetSampleSum())
+	}
+	for i, want := range []uint64{1, 2} {
+		if got := histogram.Bucket[i].GetCumulativeCount(); got != want {
+			t.Errorf("expected %d samples up to %v, got %d", want, histogram.Bucket[i].GetUpperBound(), got)
+		}
+	}
+
+	// alice is active in both children and counts once, bob's session ended.
+	if got := family(t, families, namespace+"_chat_active_sessions").Metric[0].Gauge.GetValue(); got != 2 {
+		t.Errorf("expected 2 active sessions, got %v", got)
+	}
+
+	for i := 1; i < len(families); i++ {
+		if families[i-1].GetName() > families[i].GetName() {
+			t.Fatalf("expected families sorted by name, got %s before %s", families[i-1].GetName(), families[i].GetName())
+		}
+	}
+}
+
+func TestCombineKeepsExitedChildren(t *testing.T) {
+	first, second := newChild(), newChild()
+
+	first.requests.WithLabelValues("/generate").Add(3)
+	second.requests.WithLabelValues("/generate").Add(2)
+	first.inflight.Set(2)
+	second.inflight.Set(1)
+	first.durations.Observe(5)
+	second.durations.Observe(20)
+
+	// first exited after publishing, only its last snapshot is left.
+	last := first.snapshot(t, nil)
+	last.Published = time.Now().Add(-2 * snapshotStale)
+
+	families, err := combine([]snapshot{last, second.snapshot(t, nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := series(t, family(t, families, "requests_total"), "/generate").Counter.GetValue(); got != 5 {
+		t.Errorf("expected the 5 requests of both children, got %v", got)
+	}
+	if got := family(t, families, "duration_seconds").Metric[0].Histogram.GetSampleCount(); got != 2 {
+		t.Errorf("expected the 2 samples of both children, got %d", got)
+	}
+	if got := family(t, families, "inflight").Metric[0].Gauge.GetValue(); got != 1 {
+		t.Errorf("expected only the 1 request in flight of the live child, got %v", got)
+	}
+}
+
+func TestCombineRejectsCorruptSnapshots(t *testing.T) {
+	if _, err := combine([]snapshot{{Metrics: []byte{0x05, 0xff, 0xff}}}); err == nil {
+		t.Fatal("expected an error for a corrupt snapshot")
+	}
+}
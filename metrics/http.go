@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Middleware counts and times every request by the route it matched, so
+// path parameters do not make a series per value.
+func Middleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		// Errors are turned into responses after the middleware returns.
+		status := c.Response().StatusCode()
+		var ferr *fiber.Error
+		if errors.As(err, &ferr) {
+			status = ferr.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+
+		route := c.Route().Path
+		method := c.Method()
+
+		requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		requestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
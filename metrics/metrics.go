@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"ai-test/jobs"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "ingportal"
+
+// registry holds the metrics of this process only. Gather merges those of
+// every prefork child.
+var registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route and status code.",
+	}, []string{"method", "route", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route.",
+		Buckets:   []float64{0.005, 0.025, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"method", "route"})
+
+	stageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "generation",
+		Name:      "stage_duration_seconds",
+		Help:      "Time generations spend in each status of the pipeline.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 13),
+	}, []string{"stage"})
+
+	stepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "generation",
+		Name:      "postprocessing_duration_seconds",
+		Help:      "Time each post-processing step takes, validation included.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"step"})
+
+	modelErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "model",
+		Name:      "errors_total",
+		Help:      "Failed model calls by kind of error.",
+	}, []string{"type"})
+
+	tokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "model",
+		Name:      "tokens_total",
+		Help:      "Tokens used by model calls, by model and kind of token.",
+	}, []string{"model", "type"})
+
+	queueDepth = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "queue_depth",
+		Help:      "Generations and chat messages accepted and not finished yet.",
+	}, func() float64 {
+		return float64(jobs.Running())
+	})
+)
+
+func init() {
+	registry.MustRegister(requests, requestDuration, stageDuration, stepDuration, modelErrors, tokens, queueDepth)
+}
+
+// ObserveStage records the time a generation spent in a stage, named after
+// its responses.GenerationStatus.
+func ObserveStage(stage string, duration time.Duration) {
+	stageDuration.WithLabelValues(stage).Observe(duration.Seconds())
+}
+
+func ObserveStep(step string, duration time.Duration) {
+	stepDuration.WithLabelValues(step).Observe(duration.Seconds())
+}
+
+func ModelError(kind string) {
+	modelErrors.WithLabelValues(kind).Inc()
+}
+
+// Tokens records the usage of one model call.
+func Tokens(model string, prompt int32, output int32, thoughts int32) {
+	for kind, count := range map[string]int32{"prompt": prompt, "output": output, "thoughts": thoughts} {
+		if count > 0 {
+			tokens.WithLabelValues(model, kind).Add(float64(count))
+		}
+	}
+}
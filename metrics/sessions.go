@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// sessionWindow is how long a chat counts as active after its last message.
+const sessionWindow = 15 * time.Minute
+
+// sessionTracker remembers the last chat message of every user. Prefork
+// children publish theirs, so a user served by several of them is counted
+// once.
+type sessionTracker struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+var sessions = &sessionTracker{seen: map[string]time.Time{}}
+
+// ChatActivity marks the chat session of user as active.
+func ChatActivity(user string) {
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+
+	sessions.seen[user] = time.Now()
+}
+
+// active returns the users with an active session.
+func (s *sessionTracker) active() map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for user, last := range s.seen {
+		if time.Since(last) > sessionWindow {
+			delete(s.seen, user)
+		}
+	}
+	return maps.Clone(s.seen)
+}
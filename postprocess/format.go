@@ -0,0 +1,147 @@
+package postprocess
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Formatter formats the content of the files it matches. Formatters are
+// tried in registration order, the first available one matching a file
+// formats it.
+type Formatter interface {
+	Name() string
+	Matches(filePath string) bool
+	// Available reports whether the formatter can run on this machine.
+	Available() bool
+	Format(ctx context.Context, filePath string, code string) (string, error)
+}
+
+// FormatResult tells how a generated file was formatted. A failed formatting
+// leaves the file as generated.
+type FormatResult struct {
+	Formatter string `json:"formatter"`
+	Changed   bool   `json:"changed"`
+	Error     string `json:"error,omitempty"`
+}
+
+var (
+	mu         sync.RWMutex
+	formatters []Formatter
+)
+
+// RegisterFormatter adds a formatter after the registered ones.
+func RegisterFormatter(formatter Formatter) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	formatters = append(formatters, formatter)
+}
+
+func init() {
+	// goimports also fixes the imports, go/format is the fallback without it.
+	RegisterFormatter(Command("goimports", []string{".go"}))
+	RegisterFormatter(goFormatter{})
+	RegisterFormatter(Command("prettier", []string{".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx", ".json", ".css", ".scss", ".vue", ".yaml", ".yml"}, "--stdin-filepath", "{path}"))
+	RegisterFormatter(Command("black", []string{".py"}, "--quiet", "-"))
+	RegisterFormatter(Command("rustfmt", []string{".rs"}, "--emit", "stdout", "--edition", "2021"))
+	RegisterFormatter(Command("google-java-format", []string{".java"}, "-"))
+}
+
+// Format formats a file with the first available formatter matching it. It
+// returns nil when there is none.
+func Format(ctx context.Context, filePath string, code string) (string, *FormatResult) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, formatter := range formatters {
+		if !formatter.Matches(filePath) || !formatter.Available() {
+			continue
+		}
+
+		result := &FormatResult{Formatter: formatter.Name()}
+
+		formatted, err := formatter.Format(ctx, filePath, code)
+		if err != nil {
+			result.Error = err.Error()
+			return code, result
+		}
+
+		result.Changed = formatted != code
+		return formatted, result
+	}
+
+	return code, nil
+}
+
+type goFormatter struct{}
+
+func (goFormatter) Name() string { return "go/format" }
+
+func (goFormatter) Matches(filePath string) bool { return path.Ext(filePath) == ".go" }
+
+func (goFormatter) Available() bool { return true }
+
+func (goFormatter) Format(_ context.Context, _ string, code string) (string, error) {
+	formatted, err := format.Source([]byte(code))
+	return string(formatted), err
+}
+
+// command is an external formatter reading the code from stdin and writing
+// the formatted code to stdout.
+type command struct {
+	name       string
+	extensions []string
+	args       []string
+
+	lookup sync.Once
+	path   string
+}
+
+// Command returns a formatter running the named program, if it is on PATH,
+// for files with one of the extensions. The placeholder {path} in args is
+// replaced by the path of the file being formatted.
+func Command(name string, extensions []string, args ...string) Formatter {
+	return &command{name: name, extensions: extensions, args: args}
+}
+
+func (c *command) Name() string { return c.name }
+
+func (c *command) Matches(filePath string) bool {
+	return slices.Contains(c.extensions, strings.ToLower(path.Ext(filePath)))
+}
+
+func (c *command) Available() bool {
+	c.lookup.Do(func() {
+		c.path, _ = exec.LookPath(c.name)
+	})
+	return c.path != ""
+}
+
+func (c *command) Format(ctx context.Context, filePath string, code string) (string, error) {
+	args := make([]string, len(c.args))
+	for i, arg := range c.args {
+		args[i] = strings.ReplaceAll(arg, "{path}", filePath)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.path, args...)
+	cmd.Stdin = strings.NewReader(code)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%w: %s", err, message)
+		}
+		return "", err
+	}
+
+	return stdout.String(), nil
+}
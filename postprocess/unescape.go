@@ -0,0 +1,85 @@
+package postprocess
+
+import (
+	"html"
+	"path"
+	"strings"
+)
+
+// The model sometimes HTML-escapes the code it returns. Whether an entity is
+// such an artifact or meant literally depends on the kind of file.
+type escaping int
+
+const (
+	// unescapeSource only decodes source code without a single raw quote or
+	// angle bracket, which an escaped file cannot contain. Otherwise the
+	// entities belong to the code, like in an HTML escaping helper or a test
+	// fixture.
+	unescapeSource escaping = iota
+	// unescapeIfFullyEscaped only decodes markup without a single raw tag,
+	// otherwise its entities are deliberate.
+	unescapeIfFullyEscaped
+	// unescapeCodeBlocks only decodes fenced code blocks of Markdown, entities
+	// in the prose are rendered as intended.
+	unescapeCodeBlocks
+)
+
+var escapings = map[string]escaping{
+	".html":     unescapeIfFullyEscaped,
+	".htm":      unescapeIfFullyEscaped,
+	".xhtml":    unescapeIfFullyEscaped,
+	".xml":      unescapeIfFullyEscaped,
+	".svg":      unescapeIfFullyEscaped,
+	".vue":      unescapeIfFullyEscaped,
+	".jsx":      unescapeIfFullyEscaped,
+	".tsx":      unescapeIfFullyEscaped,
+	".md":       unescapeCodeBlocks,
+	".markdown": unescapeCodeBlocks,
+}
+
+// Unescape decodes the HTML entities the model escaped the content of the
+// file at filePath with, and reports whether it changed anything.
+func Unescape(filePath string, code string) (string, bool) {
+	if html.UnescapeString(code) == code {
+		return code, false
+	}
+
+	var unescaped string
+	switch escapings[strings.ToLower(path.Ext(filePath))] {
+	case unescapeIfFullyEscaped:
+		if strings.Contains(code, "<") {
+			return code, false
+		}
+		unescaped = html.UnescapeString(code)
+	case unescapeCodeBlocks:
+		unescaped = unescapeFences(code)
+	default:
+		if strings.ContainsAny(code, "\"<>") {
+			return code, false
+		}
+		unescaped = html.UnescapeString(code)
+	}
+
+	return unescaped, unescaped != code
+}
+
+// unescapeFences decodes the lines inside ``` or ~~~ fences only.
+func unescapeFences(markdown string) string {
+	lines := strings.SplitAfter(markdown, "\n")
+
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")):
+			fence = trimmed[:3]
+		case fence != "" && strings.HasPrefix(trimmed, fence):
+			fence = ""
+		case fence != "":
+			lines[i] = html.UnescapeString(line)
+		}
+	}
+
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,130 @@
+package prompts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	extension = ".tmpl"
+	delimiter = "---"
+)
+
+// Metadata is the front matter at the top of a prompt file.
+type Metadata struct {
+	Version     string `yaml:"version" json:"version"`
+	Description string `yaml:"description" json:"description"`
+}
+
+// Prompt is one version of the system prompt. Its body is a text/template
+// rendered with the parameters of the generation it is used for.
+type Prompt struct {
+	Metadata
+	template *template.Template
+}
+
+func (p *Prompt) Render(data any) (string, error) {
+	var buf bytes.Buffer
+	if err := p.template.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering prompt %s: %w", p.Version, err)
+	}
+	return buf.String(), nil
+}
+
+// Library holds every prompt version found in a directory.
+type Library struct {
+	prompts map[string]*Prompt
+}
+
+// Load parses every *.tmpl file in dir. A file without a version in its front
+// matter is versioned by its file name.
+func Load(dir string) (*Library, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+extension))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no prompt templates found in %s", dir)
+	}
+
+	library := &Library{prompts: map[string]*Prompt{}}
+	for _, path := range paths {
+		prompt, err := parse(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, exists := library.prompts[prompt.Version]; exists {
+			return nil, fmt.Errorf("%s: duplicate prompt version %s", path, prompt.Version)
+		}
+		library.prompts[prompt.Version] = prompt
+	}
+
+	return library, nil
+}
+
+func parse(path string) (*Prompt, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := Metadata{}
+	body := string(content)
+
+	if strings.HasPrefix(body, delimiter+"\n") {
+		header, rest, found := strings.Cut(body[len(delimiter)+1:], "\n"+delimiter+"\n")
+		if !found {
+			return nil, fmt.Errorf("%s: unterminated front matter", path)
+		}
+
+		if err := yaml.Unmarshal([]byte(header), &metadata); err != nil {
+			return nil, fmt.Errorf("%s: invalid front matter: %w", path, err)
+		}
+		body = rest
+	}
+
+	if metadata.Version == "" {
+		metadata.Version = strings.TrimSuffix(filepath.Base(path), extension)
+	}
+
+	tmpl, err := template.New(metadata.Version).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &Prompt{
+		Metadata: metadata,
+		template: tmpl,
+	}, nil
+}
+
+var ErrUnknownVersion = errors.New("unknown prompt version")
+
+func (l *Library) Get(version string) (*Prompt, error) {
+	prompt, ok := l.prompts[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownVersion, version)
+	}
+	return prompt, nil
+}
+
+// Versions returns the available prompt versions in a stable order.
+func (l *Library) Versions() []string {
+	versions := make([]string, 0, len(l.prompts))
+	for version := range l.prompts {
+		versions = append(versions, version)
+	}
+
+	slices.Sort(versions)
+	return versions
+}
@@ -0,0 +1,296 @@
+package quota
+
+import (
+	"ai-test/auth"
+	"ai-test/config"
+	"ai-test/server/errors"
+	"ai-test/server/responses"
+	"ai-test/store"
+	"ai-test/util"
+	"ai-test/util/level"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	// generationLease bounds how long a generation counts as running, should
+	// the process running it die before releasing it.
+	generationLease = 15 * time.Minute
+	// concurrencyRetry is suggested to callers waiting for a generation slot.
+	concurrencyRetry = 10 * time.Second
+
+	global = "global"
+)
+
+// scope is who a limit applies to: one user or all of them together.
+type scope struct {
+	name   string
+	limits config.QuotaLimits
+}
+
+func scopes(conf config.QuotaConfig, user auth.User) []scope {
+	return []scope{
+		{name: "user:" + user.String(), limits: conf.User},
+		{name: global, limits: conf.Global},
+	}
+}
+
+func minuteKey(scope string, now time.Time) string {
+	return "quota:requests:" + scope + ":" + now.UTC().Format("200601021504")
+}
+
+// slotKey is one of the generation slots of a scope. Each running generation
+// leases a slot of its own, so a slot held by a process that died expires
+// without affecting the others.
+func slotKey(scope string, slot int64) string {
+	return "quota:generations:" + scope + ":" + strconv.FormatInt(slot, 10)
+}
+
+func tokensKey(scope string, now time.Time) string {
+	return "quota:tokens:" + scope + ":" + now.UTC().Format("20060102")
+}
+
+func nextMinute(now time.Time) time.Time {
+	return now.UTC().Truncate(time.Minute).Add(time.Minute)
+}
+
+func nextDay(now time.Time) time.Time {
+	year, month, day := now.UTC().Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+}
+
+// Limit counts the request against the requests per minute and rejects it
+// once those or the daily token budget are used up. A request rejected by
+// one scope is not counted against any other.
+func Limit() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		conf := config.Get().Quota
+		if !conf.Enabled {
+			return c.Next()
+		}
+
+		now := time.Now()
+		shared := store.Shared()
+		limited := scopes(conf, auth.UserOf(c))
+
+		for _, s := range limited {
+			if limit := s.limits.DailyTokens; limit > 0 {
+				used, err := shared.Counter(tokensKey(s.name, now))
+				if failOpen(err) {
+					continue
+				}
+				if used >= limit {
+					return reject(c, fmt.Sprintf("the %s daily token budget of %d is used up", s.name, limit), nextDay(now).Sub(now))
+				}
+			}
+		}
+
+		var counted []string
+		for _, s := range limited {
+			if limit := s.limits.RequestsPerMinute; limit > 0 {
+				key := minuteKey(s.name, now)
+				count, err := shared.Increment(key, 1, 2*time.Minute)
+				if failOpen(err) {
+					continue
+				}
+				counted = append(counted, key)
+
+				if count > limit {
+					uncount(shared, counted)
+					return reject(c, fmt.Sprintf("the %s limit of %d requests per minute is reached", s.name, limit), nextMinute(now).Sub(now))
+				}
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// uncount takes a rejected request back from the minute counters it was
+// counted in.
+func uncount(shared store.Store, keys []string) {
+	for _, key := range keys {
+		_, err := shared.Increment(key, -1, 2*time.Minute)
+		util.HandleError("Could not uncount rejected request: %v", err, level.ERROR)
+	}
+}
+
+// Concurrency holds a generation slot of the caller for the duration of the
+// request, rejecting it when none is free.
+func Concurrency() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		conf := config.Get().Quota
+		if !conf.Enabled {
+			return c.Next()
+		}
+
+		shared := store.Shared()
+
+		var held []string
+		defer func() {
+			for _, key := range held {
+				err := shared.Delete(key)
+				util.HandleError("Could not release generation slot: %v", err, level.ERROR)
+			}
+		}()
+
+		for _, s := range scopes(conf, auth.UserOf(c)) {
+			limit := s.limits.ConcurrentGenerations
+			if limit == 0 {
+				continue
+			}
+
+			key, err := acquire(shared, s.name, limit)
+			if failOpen(err) {
+				continue
+			}
+			if key == "" {
+				return reject(c, fmt.Sprintf("the %s limit of %d concurrent generations is reached", s.name, limit), concurrencyRetry)
+			}
+			held = append(held, key)
+		}
+
+		return c.Next()
+	}
+}
+
+// acquire leases the first free generation slot of scope and returns its key,
+// an empty one when all are taken.
+func acquire(shared store.Store, scope string, limit int64) (string, error) {
+	for slot := range limit {
+		key := slotKey(scope, slot)
+
+		added, err := shared.Add(key, []byte("1"), generationLease)
+		if err != nil {
+			return "", err
+		}
+		if added {
+			return key, nil
+		}
+	}
+	return "", nil
+}
+
+// running counts the leased generation slots of scope.
+func running(shared store.Store, scope string, limit int64) (int64, error) {
+	count := int64(0)
+	for slot := range limit {
+		_, err := shared.Get(slotKey(scope, slot))
+		if stderrors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Consume charges the tokens a model call used to the budget of the user
+// the call was made for.
+func Consume(ctx context.Context, tokens int64) {
+	conf := config.Get().Quota
+	if !conf.Enabled || tokens <= 0 {
+		return
+	}
+
+	user, ok := auth.FromContext(ctx)
+	if !ok {
+		user = auth.User{Id: "unknown", Method: auth.MethodAnonymous}
+	}
+
+	now := time.Now()
+	for _, s := range scopes(conf, user) {
+		_, err := store.Shared().Increment(tokensKey(s.name, now), tokens, 48*time.Hour)
+		util.HandleError("Could not record token usage: %v", err, level.ERROR)
+	}
+}
+
+// Status returns the quotas of a user and how much of them is left, and the
+// same of the global quotas all users share.
+func Status(user auth.User) (*responses.QuotaResponse, error) {
+	conf := config.Get().Quota
+	limited := scopes(conf, user)
+
+	now := time.Now()
+	shared := store.Shared()
+
+	own, err := usage(shared, limited[0], now)
+	if err != nil {
+		return nil, err
+	}
+	all, err := usage(shared, limited[1], now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.QuotaResponse{
+		HttpResponse: responses.HttpResponse{}.Zero(),
+		User:         user.String(),
+		Enabled:      conf.Enabled,
+		QuotaScope:   own,
+		Global:       all,
+		MinuteResets: nextMinute(now),
+		DayResets:    nextDay(now),
+	}, nil
+}
+
+func usage(shared store.Store, s scope, now time.Time) (responses.QuotaScope, error) {
+	used := responses.QuotaUsage{}
+	var err error
+
+	if used.RequestsPerMinute, err = shared.Counter(minuteKey(s.name, now)); err != nil {
+		return responses.QuotaScope{}, err
+	}
+	if used.ConcurrentGenerations, err = running(shared, s.name, s.limits.ConcurrentGenerations); err != nil {
+		return responses.QuotaScope{}, err
+	}
+	if used.DailyTokens, err = shared.Counter(tokensKey(s.name, now)); err != nil {
+		return responses.QuotaScope{}, err
+	}
+
+	return responses.QuotaScope{
+		Limits: s.limits,
+		Used:   used,
+		Remaining: responses.QuotaUsage{
+			RequestsPerMinute:     remaining(s.limits.RequestsPerMinute, used.RequestsPerMinute),
+			ConcurrentGenerations: remaining(s.limits.ConcurrentGenerations, used.ConcurrentGenerations),
+			DailyTokens:           remaining(s.limits.DailyTokens, used.DailyTokens),
+		},
+	}, nil
+}
+
+// remaining is -1 for unlimited quotas.
+func remaining(limit int64, used int64) int64 {
+	if limit == 0 {
+		return -1
+	}
+	return max(limit-used, 0)
+}
+
+// failOpen lets requests through when the store fails, a broken store must
+// not take the API down with it.
+func failOpen(err error) bool {
+	if err != nil {
+		log.Errorf("Could not check quota, allowing the request: %v", err)
+		return true
+	}
+	return false
+}
+
+func reject(c fiber.Ctx, reason string, retryAfter time.Duration) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(seconds, 1)))
+
+	log.Infof("Rate limited %s %s for %s: %s", c.Method(), c.Path(), auth.UserOf(c), reason)
+	errors.RateLimitedError.WithDetails(reason).Send(c)
+	return nil
+}
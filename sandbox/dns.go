@@ -0,0 +1,98 @@
+package sandbox
+
+import (
+	"net"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// resolver answers the DNS queries of the client under test: the mocked
+// hosts resolve to the loopback address, every other name does not exist.
+// The client has no other network anyway, this makes its lookups fail fast.
+type resolver struct {
+	conn  net.PacketConn
+	hosts map[string]bool
+}
+
+func newResolver(addr string, hosts ...string) (*resolver, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &resolver{conn: conn, hosts: map[string]bool{}}
+	for _, host := range hosts {
+		r.hosts[strings.ToLower(host)+"."] = true
+	}
+
+	go r.serve()
+	return r, nil
+}
+
+func (r *resolver) close() {
+	r.conn.Close()
+}
+
+func (r *resolver) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		answer, err := r.answer(buf[:n])
+		if err != nil {
+			continue
+		}
+		r.conn.WriteTo(answer, addr)
+	}
+}
+
+func (r *resolver) answer(query []byte) ([]byte, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil, err
+	}
+
+	question, err := parser.Question()
+	if err != nil {
+		return nil, err
+	}
+
+	known := r.hosts[strings.ToLower(question.Name.String())]
+
+	header.Response = true
+	header.Authoritative = true
+	header.RCode = dnsmessage.RCodeSuccess
+	if !known {
+		header.RCode = dnsmessage.RCodeNameError
+	}
+
+	builder := dnsmessage.NewBuilder(nil, header)
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := builder.Question(question); err != nil {
+		return nil, err
+	}
+	if err := builder.StartAnswers(); err != nil {
+		return nil, err
+	}
+
+	// Only IPv4 is answered, an empty AAAA answer makes the client use it.
+	if known && question.Type == dnsmessage.TypeA {
+		err := builder.AResource(
+			dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 60},
+			dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return builder.Finish()
+}
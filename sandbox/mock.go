@@ -0,0 +1,216 @@
+package sandbox
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Call is an HTTP request the client under test made to the mock sandbox.
+type Call struct {
+	Method            string `json:"method"`
+	Path              string `json:"path"`
+	Status            int    `json:"status"`
+	ClientCertificate bool   `json:"clientCertificate"`
+	AccessToken       bool   `json:"accessToken"`
+	Signature         bool   `json:"signature"`
+}
+
+// maxCalls caps the calls recorded of a client.
+const maxCalls = 1000
+
+// greetings are the Showcase API endpoints the mock implements.
+var greetings = []string{"/greetings/single", "/signed/greetings", "/mtls-only/greetings"}
+
+// mock imitates the sandbox for a single client: it issues application
+// access tokens to it over mTLS and serves the Showcase greetings to the
+// holders of those tokens.
+type mock struct {
+	clientId string
+	server   *http.Server
+	listener net.Listener
+
+	mu     sync.Mutex
+	tokens map[string]bool
+	calls  []Call
+}
+
+func newMock(addr string, host string, clientId string, ca *authority) (*mock, error) {
+	certificate, err := ca.serverCertificate(host)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := tls.Listen("tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		// Any certificate will do, the client uses a throwaway one.
+		ClientAuth: tls.RequestClientCert,
+		MinVersion: tls.VersionTLS12,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m := &mock{
+		clientId: clientId,
+		listener: listener,
+		tokens:   map[string]bool{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /oauth2/token", m.token)
+	for _, path := range greetings {
+		mux.HandleFunc("GET "+path, m.greeting)
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		m.error(w, http.StatusNotFound, "not_found", "the mock sandbox does not implement "+r.Method+" "+r.URL.Path)
+	})
+
+	m.server = &http.Server{
+		Handler:           m.record(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go m.server.Serve(listener)
+
+	return m, nil
+}
+
+func (m *mock) close() {
+	m.server.Close()
+}
+
+func (m *mock) recorded() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Clone(m.calls)
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (m *mock) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(writer, r)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if len(m.calls) >= maxCalls {
+			return
+		}
+		m.calls = append(m.calls, Call{
+			Method:            r.Method,
+			Path:              r.URL.Path,
+			Status:            writer.status,
+			ClientCertificate: hasClientCertificate(r),
+			AccessToken:       m.tokens[bearer(r)],
+			Signature:         r.Header.Get("Signature") != "" || r.Header.Get("X-JWS-Signature") != "",
+		})
+	})
+}
+
+func (m *mock) token(w http.ResponseWriter, r *http.Request) {
+	if !hasClientCertificate(r) {
+		m.error(w, http.StatusUnauthorized, "invalid_client", "a client certificate is required")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		m.error(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	if grant := r.PostForm.Get("grant_type"); grant != "client_credentials" {
+		m.error(w, http.StatusBadRequest, "unsupported_grant_type", "the mock sandbox only issues application access tokens, got grant_type "+grant)
+		return
+	}
+
+	if clientId := r.PostForm.Get("client_id"); clientId != m.clientId {
+		m.error(w, http.StatusUnauthorized, "invalid_client", "unknown client_id "+clientId)
+		return
+	}
+
+	token := randomHex(32)
+
+	m.mu.Lock()
+	m.tokens[token] = true
+	m.mu.Unlock()
+
+	m.json(w, http.StatusOK, map[string]any{
+		"access_token": token,
+		"expires_in":   900,
+		"scope":        "greetings:view",
+		"token_type":   "Bearer",
+		"keys":         []any{},
+		"client_id":    m.clientId,
+	})
+}
+
+func (m *mock) greeting(w http.ResponseWriter, r *http.Request) {
+	if !hasClientCertificate(r) {
+		m.error(w, http.StatusUnauthorized, "invalid_client", "a client certificate is required")
+		return
+	}
+
+	m.mu.Lock()
+	authorized := m.tokens[bearer(r)]
+	m.mu.Unlock()
+
+	if !authorized {
+		m.error(w, http.StatusUnauthorized, "invalid_token", "a valid application access token is required")
+		return
+	}
+
+	m.json(w, http.StatusOK, map[string]any{
+		"message":          "Welcome to ING",
+		"id":               randomHex(16),
+		"messageTimestamp": time.Now().UTC().Format("2006-01-02 15:04:05 MST"),
+	})
+}
+
+func (m *mock) error(w http.ResponseWriter, status int, code string, message string) {
+	m.json(w, status, map[string]string{
+		"error":             code,
+		"error_description": message,
+	})
+}
+
+func (m *mock) json(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func hasClientCertificate(r *http.Request) bool {
+	return r.TLS != nil && len(r.TLS.PeerCertificates) > 0
+}
+
+func bearer(r *http.Request) string {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,246 @@
+package sandbox
+
+import (
+	"ai-test/fileset"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// File is a file of the generated project.
+type File struct {
+	Path string
+	Code string
+}
+
+// Options describes the client identity the generated client uses, which the
+// mock sandbox expects.
+type Options struct {
+	ClientId        string
+	Host            string
+	CertificatePath string
+	KeyPath         string
+	// Timeout bounds the build and the run together.
+	Timeout time.Duration
+}
+
+// Report is the outcome of running a generated client against the mock
+// sandbox. Error is set when the run could not take place at all.
+type Report struct {
+	Built         bool          `json:"built"`
+	BuildOutput   string        `json:"buildOutput,omitempty"`
+	ExitCode      int           `json:"exitCode"`
+	Stdout        string        `json:"stdout"`
+	Stderr        string        `json:"stderr"`
+	TimedOut      bool          `json:"timedOut"`
+	Duration      time.Duration `json:"duration"`
+	Calls         []Call        `json:"calls"`
+	Authenticated bool          `json:"authenticated"`
+	Endpoints     []string      `json:"endpoints"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// Passed reports whether the client built, authenticated, called at least
+// one endpoint successfully and exited cleanly.
+func (r Report) Passed() bool {
+	return r.Built && r.ExitCode == 0 && r.Authenticated && len(r.Endpoints) > 0
+}
+
+// outputLimit caps the captured build output, stdout and stderr each.
+const outputLimit = 16 * 1024
+
+var (
+	packageMain = regexp.MustCompile(`(?m)^package main\b`)
+	funcMain    = regexp.MustCompile(`(?m)^func main\(\)`)
+)
+
+// Run builds a generated Go client and runs it against a mock of the
+// sandbox. The client runs isolated by a supervisor process, see supervise:
+// it only sees its project read-only, only reaches the mock over loopback and
+// runs with its resources limited. The client gets a throwaway certificate at
+// the configured paths and trusts the mock's CA. Where the client cannot be
+// isolated it is not run at all.
+func Run(ctx context.Context, files []File, opts Options) Report {
+	goBinary, err := exec.LookPath("go")
+	if err != nil {
+		return Report{Error: "the Go toolchain is not available on this server"}
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	workDir, err := os.MkdirTemp("", "ing-portal-sandbox-*")
+	if err != nil {
+		return Report{Error: err.Error()}
+	}
+	defer os.RemoveAll(workDir)
+
+	mainDir, err := writeProject(filepath.Join(workDir, projectDir), files, opts)
+	if err != nil {
+		return Report{Error: err.Error()}
+	}
+
+	report := Report{}
+	start := time.Now()
+
+	build := exec.CommandContext(ctx, goBinary, "build", "-o", filepath.Join(workDir, clientBinary), ".")
+	build.Dir = mainDir
+	build.Env = buildEnv(workDir)
+
+	output, err := build.CombinedOutput()
+	report.BuildOutput = truncate(string(output))
+	if err != nil {
+		report.TimedOut = ctx.Err() != nil
+		report.Duration = time.Since(start)
+		return report
+	}
+	report.Built = true
+
+	result, err := isolated(ctx, job{
+		Dir:      workDir,
+		Host:     opts.Host,
+		ClientId: opts.ClientId,
+		Timeout:  runTimeout(ctx),
+	})
+	report.Duration = time.Since(start)
+	report.TimedOut = ctx.Err() != nil || result.TimedOut
+	report.ExitCode = -1
+	if err != nil {
+		if !report.TimedOut {
+			report.Error = "could not run the client isolated: " + err.Error()
+		}
+		return report
+	}
+
+	report.Stdout = result.Stdout
+	report.Stderr = result.Stderr
+	report.ExitCode = result.ExitCode
+	report.Error = result.Error
+
+	report.Calls = result.Calls
+	for _, call := range report.Calls {
+		if call.Method == "POST" && call.Path == "/oauth2/token" && call.Status == 200 {
+			report.Authenticated = true
+		}
+		if slices.Contains(greetings, call.Path) && call.Status == 200 && !slices.Contains(report.Endpoints, call.Path) {
+			report.Endpoints = append(report.Endpoints, call.Path)
+		}
+	}
+
+	return report
+}
+
+// runTimeout is how long the client may run, leaving the supervisor time to
+// report before ctx is done.
+func runTimeout(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	return max(time.Until(deadline)-reportMargin, time.Second)
+}
+
+// writeProject writes the files with the client certificate. It returns the
+// directory of the main package.
+func writeProject(dir string, files []File, opts Options) (string, error) {
+	certificate, key, err := clientCertificate(opts.ClientId)
+	if err != nil {
+		return "", err
+	}
+
+	contents := map[string][]byte{
+		opts.CertificatePath: certificate,
+		opts.KeyPath:         key,
+	}
+
+	mainDir := ""
+
+	for _, file := range files {
+		path, err := fileset.CleanPath(file.Path)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := contents[path]; ok {
+			continue
+		}
+		contents[path] = []byte(file.Code)
+
+		if filepath.Ext(path) == ".go" && packageMain.MatchString(file.Code) && funcMain.MatchString(file.Code) {
+			mainDir = filepath.Dir(path)
+		}
+	}
+
+	if mainDir == "" {
+		return "", errors.New("no main package found in the generated files")
+	}
+
+	for path, content := range contents {
+		target := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(target, content, 0o600); err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(mainDir)), nil
+}
+
+// buildEnv keeps the build offline, generated clients are expected to only
+// use the standard library. The build cache is shared between runs.
+func buildEnv(workDir string) []string {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		cache = os.TempDir()
+	}
+
+	return []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + workDir,
+		"GOPATH=" + filepath.Join(workDir, "gopath"),
+		"GOCACHE=" + filepath.Join(cache, "ing-portal-sandbox"),
+		"GOPROXY=off",
+		"GOFLAGS=-mod=mod",
+		"GOTOOLCHAIN=local",
+		"CGO_ENABLED=0",
+	}
+}
+
+type limitedBuffer struct {
+	bytes.Buffer
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := outputLimit - b.Len(); room < len(p) {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.Buffer.String() + "\n[output truncated]"
+	}
+	return b.Buffer.String()
+}
+
+func truncate(s string) string {
+	if len(s) <= outputLimit {
+		return s
+	}
+	return s[:outputLimit] + fmt.Sprintf("\n[%d bytes truncated]", len(s)-outputLimit)
+}
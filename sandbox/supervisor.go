@@ -0,0 +1,165 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"syscall"
+	"time"
+)
+
+// supervisorEnv marks the server process that is re-executed to supervise a
+// client run.
+const supervisorEnv = "ING_PORTAL_SANDBOX_SUPERVISOR"
+
+// outcomeFd is the descriptor the supervisor reports the outcome on.
+const outcomeFd = 3
+
+// reportMargin is the time the supervisor is left to report once the client
+// ran out of time.
+const reportMargin = 2 * time.Second
+
+// The layout of the work directory, which the client sees read-only at
+// sandboxDir.
+const (
+	projectDir   = "project"
+	clientBinary = "client"
+	sandboxDir   = "/sandbox"
+	caFile       = "/etc/ssl/sandbox-ca.pem"
+)
+
+// Limits of the client and its supervisor, they share a process tree.
+const (
+	cpuMargin      = 5 * time.Second
+	memoryLimit    = 1 << 30
+	fileSizeLimit  = 16 << 20
+	openFilesLimit = 256
+	processLimit   = 256
+	tmpSize        = "64m"
+)
+
+// The addresses the mock and its resolver listen on inside the client's
+// network namespace, where nothing else is reachable.
+const (
+	mockAddr     = "127.0.0.1:443"
+	resolverAddr = "127.0.0.1:53"
+)
+
+// job is what the supervisor is asked to run.
+type job struct {
+	Dir      string        `json:"dir"`
+	Host     string        `json:"host"`
+	ClientId string        `json:"clientId"`
+	Timeout  time.Duration `json:"timeout"`
+}
+
+// outcome is what the supervisor reports of a run.
+type outcome struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exitCode"`
+	TimedOut bool   `json:"timedOut"`
+	Calls    []Call `json:"calls"`
+	Error    string `json:"error,omitempty"`
+}
+
+// IsSupervisor reports whether this process was started to supervise a
+// client run, the server must then call Supervise instead of starting.
+func IsSupervisor() bool {
+	return os.Getenv(supervisorEnv) != ""
+}
+
+// Supervise runs the client of the job read from stdin inside the
+// namespaces the process was started in and reports the outcome.
+func Supervise() {
+	report := os.NewFile(outcomeFd, "outcome")
+	// The client must not be able to report in place of the supervisor.
+	syscall.CloseOnExec(outcomeFd)
+
+	j := job{}
+	result := outcome{ExitCode: -1}
+	if err := json.NewDecoder(os.Stdin).Decode(&j); err != nil {
+		result.Error = err.Error()
+	} else {
+		result = supervise(j)
+	}
+
+	if err := json.NewEncoder(report).Encode(result); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// supervise sets up what the client sees and runs it, see isolate.
+func supervise(j job) outcome {
+	result := outcome{ExitCode: -1}
+
+	ca, err := newAuthority()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	if err := isolate(j, ca.pem); err != nil {
+		result.Error = "could not isolate the client: " + err.Error()
+		return result
+	}
+
+	server, err := newMock(mockAddr, j.Host, j.ClientId, ca)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer server.close()
+
+	dns, err := newResolver(resolverAddr, j.Host)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer dns.close()
+
+	ctx := context.Background()
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr limitedBuffer
+	run := exec.CommandContext(ctx, path.Join(sandboxDir, clientBinary))
+	run.Dir = path.Join(sandboxDir, projectDir)
+	run.Env = []string{
+		"PATH=/usr/local/bin:/usr/bin:/bin",
+		"HOME=/tmp",
+		"TMPDIR=/tmp",
+		"SSL_CERT_FILE=" + caFile,
+	}
+	run.Stdout = &stdout
+	run.Stderr = &stderr
+	run.WaitDelay = time.Second
+
+	err = start(run)
+	if err == nil {
+		err = run.Wait()
+	}
+
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	if run.ProcessState != nil {
+		result.ExitCode = run.ProcessState.ExitCode()
+	}
+	result.TimedOut = ctx.Err() != nil
+
+	var exit *exec.ExitError
+	if err != nil && !errors.As(err, &exit) && !result.TimedOut {
+		result.Error = err.Error()
+	}
+
+	result.Calls = server.recorded()
+	return result
+}
@@ -0,0 +1,261 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// isolated runs j in a supervisor in new user, mount, network, PID, IPC and
+// UTS namespaces. The supervisor is this executable; it is root of its own
+// user namespace only, mapped to the server user, and cannot reach anything
+// on the network of the host.
+func isolated(ctx context.Context, j job) (outcome, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return outcome{}, err
+	}
+
+	input, err := json.Marshal(j)
+	if err != nil {
+		return outcome{}, err
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return outcome{}, err
+	}
+	defer reader.Close()
+
+	var stderr limitedBuffer
+	supervisor := exec.CommandContext(ctx, self)
+	supervisor.Env = []string{supervisorEnv + "=1"}
+	supervisor.Stdin = bytes.NewReader(input)
+	supervisor.Stderr = &stderr
+	supervisor.ExtraFiles = []*os.File{writer}
+	supervisor.WaitDelay = time.Second
+	supervisor.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET |
+			syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		Pdeathsig:   syscall.SIGKILL,
+	}
+
+	err = supervisor.Start()
+	writer.Close()
+	if err != nil {
+		return outcome{}, err
+	}
+
+	data, err := io.ReadAll(reader)
+	waitErr := supervisor.Wait()
+	if err != nil {
+		return outcome{}, err
+	}
+
+	result := outcome{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		if waitErr != nil {
+			return outcome{}, fmt.Errorf("%w: %s", waitErr, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return outcome{}, err
+	}
+	return result, nil
+}
+
+// isolate leaves the supervisor, and so the client, with the loopback
+// interface as its only network, the work directory read-only at sandboxDir,
+// a small writable /tmp and nothing else of the host's file system. The
+// resources of the process tree are limited.
+func isolate(j job, caPem []byte) error {
+	if err := loopbackUp(); err != nil {
+		return fmt.Errorf("bringing up the loopback interface: %w", err)
+	}
+	if err := enterRoot(j.Dir, caPem); err != nil {
+		return fmt.Errorf("entering the client's root: %w", err)
+	}
+	if err := limit(j.Timeout); err != nil {
+		return fmt.Errorf("limiting resources: %w", err)
+	}
+	return nil
+}
+
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
+
+// devices are the device nodes of the host the client may use.
+var devices = []string{"null", "zero", "random", "urandom"}
+
+// enterRoot builds a new root on a tmpfs in dir and pivots into it.
+func enterRoot(dir string, caPem []byte) error {
+	// Nothing mounted from here on propagates back to the host.
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return err
+	}
+
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0o700); err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "size=1m,mode=0755"); err != nil {
+		return err
+	}
+
+	for _, name := range []string{"dev", "etc/ssl", "sandbox", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(root, name), 0o755); err != nil {
+			return err
+		}
+	}
+
+	files := map[string][]byte{
+		"etc/hosts":       []byte("127.0.0.1 localhost\n"),
+		"etc/resolv.conf": []byte("nameserver 127.0.0.1\n"),
+		caFile[1:]:        caPem,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), content, 0o644); err != nil {
+			return err
+		}
+	}
+
+	for _, device := range devices {
+		target := filepath.Join(root, "dev", device)
+		if err := os.WriteFile(target, nil, 0o644); err != nil {
+			return err
+		}
+		if err := unix.Mount(filepath.Join("/dev", device), target, "", unix.MS_BIND, ""); err != nil {
+			return err
+		}
+	}
+
+	sandbox := filepath.Join(root, sandboxDir)
+	if err := unix.Mount(dir, sandbox, "", unix.MS_BIND, ""); err != nil {
+		return err
+	}
+	if err := readOnly(sandbox); err != nil {
+		return err
+	}
+
+	if err := unix.Mount("tmpfs", filepath.Join(root, "tmp"), "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "size="+tmpSize+",mode=1777"); err != nil {
+		return err
+	}
+
+	if err := unix.Chdir(root); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return err
+	}
+	// The old root is stacked under the new one, detaching it leaves the
+	// host's file system unreachable.
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return err
+	}
+	if err := unix.Chdir("/"); err != nil {
+		return err
+	}
+
+	return readOnly("/")
+}
+
+// lockedFlags maps the statfs flags of a mount to the mount flags a remount
+// inside a user namespace has to keep.
+var lockedFlags = map[int64]uintptr{
+	unix.ST_NOSUID:     unix.MS_NOSUID,
+	unix.ST_NODEV:      unix.MS_NODEV,
+	unix.ST_NOEXEC:     unix.MS_NOEXEC,
+	unix.ST_NOATIME:    unix.MS_NOATIME,
+	unix.ST_NODIRATIME: unix.MS_NODIRATIME,
+	unix.ST_RELATIME:   unix.MS_RELATIME,
+}
+
+func readOnly(target string) error {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(target, &stat); err != nil {
+		return err
+	}
+
+	flags := uintptr(unix.MS_REMOUNT | unix.MS_BIND | unix.MS_RDONLY | unix.MS_NOSUID | unix.MS_NODEV)
+	for st, ms := range lockedFlags {
+		if stat.Flags&st != 0 {
+			flags |= ms
+		}
+	}
+	return unix.Mount("", target, "", flags, "")
+}
+
+func limit(timeout time.Duration) error {
+	limits := map[int]uint64{
+		unix.RLIMIT_DATA:   memoryLimit,
+		unix.RLIMIT_FSIZE:  fileSizeLimit,
+		unix.RLIMIT_NOFILE: openFilesLimit,
+		unix.RLIMIT_NPROC:  processLimit,
+		unix.RLIMIT_CORE:   0,
+	}
+	if timeout > 0 {
+		limits[unix.RLIMIT_CPU] = uint64((timeout + cpuMargin).Seconds())
+	}
+
+	for resource, value := range limits {
+		// syscall.Setrlimit, unlike unix.Setrlimit, keeps the runtime from
+		// restoring its own open files limit in the client.
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: value, Max: value}); err != nil {
+			return fmt.Errorf("resource %d: %w", resource, err)
+		}
+	}
+	return nil
+}
+
+// start starts the client without any capability: the supervisor needed
+// them to set up the namespaces, the client must not undo that. The
+// capability bounding set is per thread, the client is started from the
+// thread that dropped it.
+func start(cmd *exec.Cmd) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	for capability := 0; ; capability++ {
+		err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(capability), 0, 0, 0)
+		if errors.Is(err, unix.EINVAL) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("dropping capability %d: %w", capability, err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return err
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+	return cmd.Start()
+}
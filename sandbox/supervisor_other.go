@@ -0,0 +1,23 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+var errUnsupported = errors.New("clients can only be isolated on Linux")
+
+func isolated(context.Context, job) (outcome, error) {
+	return outcome{}, errUnsupported
+}
+
+func isolate(job, []byte) error {
+	return errUnsupported
+}
+
+func start(*exec.Cmd) error {
+	return errUnsupported
+}
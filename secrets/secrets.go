@@ -0,0 +1,221 @@
+package secrets
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type Severity string
+
+const (
+	High   Severity = "high"
+	Medium Severity = "medium"
+)
+
+// Finding is a suspected secret in a generated file. Match is masked so the
+// secret itself is never echoed back.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Match    string   `json:"match"`
+
+	start, end int
+}
+
+type rule struct {
+	name     string
+	severity Severity
+	pattern  *regexp.Regexp
+	// group selects the submatch holding the secret, 0 is the whole match.
+	group int
+	// accept filters out matches that are not secrets after all.
+	accept func(secret string) bool
+}
+
+var rules = []rule{
+	{
+		name:     "private_key",
+		severity: High,
+		pattern:  regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?(-----END [A-Z ]*PRIVATE KEY-----|$)`),
+	},
+	{
+		name:     "pem_block",
+		severity: Medium,
+		pattern:  regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]+-----.*?(-----END [A-Z0-9 ]+-----|$)`),
+	},
+	{
+		name:     "jwt",
+		severity: High,
+		pattern:  regexp.MustCompile(`eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`),
+	},
+	{
+		name:     "bearer_token",
+		severity: High,
+		pattern:  regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]{20,}=*)`),
+		group:    1,
+	},
+	{
+		name:     "aws_access_key",
+		severity: High,
+		pattern:  regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`),
+	},
+	{
+		name:     "google_api_key",
+		severity: High,
+		pattern:  regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`),
+	},
+	{
+		name:     "hardcoded_secret",
+		severity: High,
+		pattern:  regexp.MustCompile(`(?i)\b(password|passwd|secret|client_?secret|api_?key|access_?token|private_?key)["']?\s*[:=]\s*["']([^"'\s]{6,})["']`),
+		group:    2,
+		accept:   notPlaceholder,
+	},
+	{
+		name:     "high_entropy_string",
+		severity: Medium,
+		pattern:  regexp.MustCompile("[\"'`]([A-Za-z0-9+/=_-]{20,})[\"'`]"),
+		group:    1,
+		accept:   highEntropy,
+	},
+}
+
+// Scanner looks for secrets in file contents. Values in allowed, such as the
+// public sandbox client id, are never reported.
+type Scanner struct {
+	allowed []string
+}
+
+func NewScanner(allowed ...string) *Scanner {
+	lowered := make([]string, 0, len(allowed))
+	for _, value := range allowed {
+		lowered = append(lowered, strings.ToLower(value))
+	}
+
+	return &Scanner{allowed: lowered}
+}
+
+// Scan returns the findings in content ordered by position. Overlapping
+// matches are reported once, by the first rule matching them.
+func (s *Scanner) Scan(content string) []Finding {
+	var findings []Finding
+
+	for _, r := range rules {
+		for _, match := range r.pattern.FindAllStringSubmatchIndex(content, -1) {
+			start, end := match[2*r.group], match[2*r.group+1]
+			if start < 0 {
+				continue
+			}
+
+			secret := content[start:end]
+			if s.isAllowed(secret) || (r.accept != nil && !r.accept(secret)) {
+				continue
+			}
+
+			if overlaps(findings, start, end) {
+				continue
+			}
+
+			line, column := position(content, start)
+			findings = append(findings, Finding{
+				Rule:     r.name,
+				Severity: r.severity,
+				Line:     line,
+				Column:   column,
+				Match:    mask(secret),
+				start:    start,
+				end:      end,
+			})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].start < findings[j].start
+	})
+
+	return findings
+}
+
+// Redact replaces every finding in content with a marker naming its rule.
+// findings must come from scanning the same content.
+func Redact(content string, findings []Finding) string {
+	var b strings.Builder
+	last := 0
+
+	for _, finding := range findings {
+		b.WriteString(content[last:finding.start])
+		b.WriteString("REDACTED_" + strings.ToUpper(finding.Rule))
+		last = finding.end
+	}
+	b.WriteString(content[last:])
+
+	return b.String()
+}
+
+func (s *Scanner) isAllowed(secret string) bool {
+	secret = strings.ToLower(secret)
+	return slices.ContainsFunc(s.allowed, func(allowed string) bool {
+		return strings.Contains(secret, allowed) || strings.Contains(allowed, secret)
+	})
+}
+
+func overlaps(findings []Finding, start, end int) bool {
+	return slices.ContainsFunc(findings, func(f Finding) bool {
+		return start < f.end && f.start < end
+	})
+}
+
+func position(content string, offset int) (int, int) {
+	line := strings.Count(content[:offset], "\n") + 1
+	column := offset - strings.LastIndex(content[:offset], "\n")
+	return line, column
+}
+
+func mask(secret string) string {
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return secret[:4] + "****"
+}
+
+var placeholders = []string{"your", "example", "changeme", "placeholder", "xxx", "<", "${", "{{", "todo", "redacted"}
+
+func notPlaceholder(secret string) bool {
+	lowered := strings.ToLower(secret)
+	return !slices.ContainsFunc(placeholders, func(p string) bool {
+		return strings.Contains(lowered, p)
+	})
+}
+
+// highEntropy tells random-looking tokens apart from identifiers and paths.
+// Hex strings carry at most 4 bits per character, so they get a lower bar.
+func highEntropy(secret string) bool {
+	if strings.Trim(strings.ToLower(secret), "0123456789abcdef") == "" {
+		return len(secret) >= 32 && entropy(secret) > 3.0
+	}
+
+	if !strings.ContainsAny(secret, "0123456789") {
+		return false
+	}
+	return entropy(secret) > 4.5
+}
+
+// entropy is the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, c := range s {
+		counts[c]++
+	}
+
+	var bits float64
+	for _, count := range counts {
+		p := float64(count) / float64(len(s))
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
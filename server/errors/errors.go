@@ -4,6 +4,8 @@ import (
 	"ai-test/server/responses"
 	"ai-test/util"
 	"ai-test/util/level"
+	stderrors "errors"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
@@ -11,20 +13,137 @@ import (
 
 var (
 	BadRequestError = HttpError{
-		Code:    400,
-		Message: "There was an error with your request. Please try again.",
+		Code:      400,
+		ErrorCode: "bad_request",
+		Message:   "There was an error with your request. Please try again.",
+	}
+
+	ChatNotStartedError = HttpError{
+		Code:      400,
+		ErrorCode: "chat_not_started",
+		Message:   "Chat must be initialized first",
+	}
+
+	UnknownProfileError = HttpError{
+		Code:      400,
+		ErrorCode: "unknown_profile",
+		Message:   "The selected model profile does not exist.",
+	}
+
+	UnknownPromptVersionError = HttpError{
+		Code:      400,
+		ErrorCode: "unknown_prompt_version",
+		Message:   "The selected prompt version does not exist.",
+	}
+
+	UnsupportedLanguageError = HttpError{
+		Code:      400,
+		ErrorCode: "unsupported_language",
+		Message:   "The selected language is not supported.",
+	}
+
+	InvalidIdentityError = HttpError{
+		Code:      400,
+		ErrorCode: "invalid_client_identity",
+		Message:   "The client identity is invalid.",
 	}
 
 	InternalServerError = HttpError{
-		Code:    500,
-		Message: "There was an error processing your request. Please try again later.",
+		Code:      500,
+		ErrorCode: "internal_error",
+		Message:   "There was an error processing your request. Please try again later.",
+	}
+
+	QuotaExceededError = HttpError{
+		Code:      429,
+		ErrorCode: "quota_exceeded",
+		Message:   "The AI model quota has been exceeded. Please try again in a few minutes.",
+	}
+
+	SafetyBlockedError = HttpError{
+		Code:      422,
+		ErrorCode: "safety_blocked",
+		Message:   "The request was blocked by the AI model's safety filters. Please rephrase your prompt.",
+	}
+
+	InvalidOutputError = HttpError{
+		Code:      502,
+		ErrorCode: "invalid_output",
+		Message:   "The AI model returned an output that could not be processed. Please try again.",
+	}
+
+	UpstreamUnavailableError = HttpError{
+		Code:      502,
+		ErrorCode: "upstream_unavailable",
+		Message:   "The AI model is currently unavailable. Please try again later.",
+	}
+
+	ShuttingDownError = HttpError{
+		Code:      503,
+		ErrorCode: "shutting_down",
+		Message:   "The server is shutting down and takes no new work. Please try again.",
+	}
+
+	CircuitOpenError = HttpError{
+		Code:      503,
+		ErrorCode: "circuit_open",
+		Message:   "The AI model is failing repeatedly and calls are paused for a moment. Please try again shortly.",
+	}
+
+	CanceledError = HttpError{
+		Code:      499,
+		ErrorCode: "canceled",
+		Message:   "The request was canceled before it completed.",
+	}
+
+	JobConflictError = HttpError{
+		Code:      409,
+		ErrorCode: "job_conflict",
+		Message:   "A job with this id is already running.",
+	}
+
+	JobNotFoundError = HttpError{
+		Code:      404,
+		ErrorCode: "job_not_found",
+		Message:   "No running job with this id was found.",
+	}
+
+	UnauthorizedError = HttpError{
+		Code:      401,
+		ErrorCode: "unauthorized",
+		Message:   "Valid credentials are required to use the API.",
+	}
+
+	ForbiddenError = HttpError{
+		Code:      403,
+		ErrorCode: "forbidden",
+		Message:   "You are not allowed to use this endpoint.",
+	}
+
+	RateLimitedError = HttpError{
+		Code:      429,
+		ErrorCode: "rate_limited",
+		Message:   "Too many requests, please try again later:",
+	}
+
+	TimeoutError = HttpError{
+		Code:      504,
+		ErrorCode: "timeout",
+		Message:   "The AI model took too long to respond. Please try again.",
 	}
 )
 
 type HttpError struct {
 	responses.HttpResponse
-	Code    int    `json:"-"`
-	Message string `json:"message"`
+	Code      int    `json:"-"`
+	ErrorCode string `json:"errorCode,omitempty"`
+	Message   string `json:"message"`
+}
+
+// WithDetails returns a copy of the error with details appended to its message.
+func (e HttpError) WithDetails(details ...string) *HttpError {
+	e.Message = e.Message + " " + strings.Join(details, "; ")
+	return &e
 }
 
 func (e *HttpError) Send(c fiber.Ctx) {
@@ -33,3 +152,19 @@ func (e *HttpError) Send(c fiber.Ctx) {
 		util.HandleError("Could not send error response: %v", err, level.ERROR)
 	}
 }
+
+// Handler is the Fiber error handler. Errors raised by Fiber itself keep their
+// default behaviour, anything else (including recovered panics) is reported
+// as an InternalServerError.
+func Handler(c fiber.Ctx, err error) error {
+	var fiberErr *fiber.Error
+	if stderrors.As(err, &fiberErr) {
+		return fiber.DefaultErrorHandler(c, err)
+	}
+
+	util.HandleError("Unhandled error: %v", err, level.ERROR)
+
+	herr := InternalServerError
+	herr.Send(c)
+	return nil
+}
@@ -1,6 +1,19 @@
 package responses
 
-import "time"
+import (
+	"ai-test/buildinfo"
+	"ai-test/config"
+	"ai-test/coverage"
+	"ai-test/fileset"
+	"ai-test/health"
+	"ai-test/identity"
+	"ai-test/languages"
+	"ai-test/postprocess"
+	"ai-test/sandbox"
+	"ai-test/secrets"
+	"ai-test/syntax"
+	"time"
+)
 
 type HttpResponse struct {
 	Time time.Time `json:"time"`
@@ -30,6 +43,7 @@ const (
 	NotStarted GenerationStatus = "not_started"
 	Generating GenerationStatus = "generating"
 	Generated  GenerationStatus = "generated"
+	Converting GenerationStatus = "converting"
 	Formatting GenerationStatus = "formatting"
 	Done       GenerationStatus = "done"
 )
@@ -49,11 +63,194 @@ func NewGenerationStatusResponse(status GenerationStatus) GenerationStatusRespon
 }
 
 type GeneratedFile struct {
-	FilePath string `json:"filePath"`
-	Code     string `json:"code"`
+	FilePath    string                    `json:"filePath"`
+	Code        string                    `json:"code"`
+	Secrets     []secrets.Finding         `json:"secrets,omitempty"`
+	Formatting  *postprocess.FormatResult `json:"formatting,omitempty"`
+	Diagnostics []syntax.Diagnostic       `json:"diagnostics,omitempty"`
 }
 
 type GenerationResponse struct {
 	HttpResponse
-	Files []GeneratedFile `json:"files"`
+	JobId          string                    `json:"jobId,omitempty"`
+	User           string                    `json:"user,omitempty"`
+	Profile        string                    `json:"profile"`
+	PromptVersion  string                    `json:"promptVersion"`
+	Files          []GeneratedFile           `json:"files"`
+	Manifest       *languages.ManifestReport `json:"manifest,omitempty"`
+	ClientIdentity *identity.ClientIdentity  `json:"clientIdentity,omitempty"`
+	IdentityCheck  *identity.Report          `json:"identityCheck,omitempty"`
+	Coverage       *coverage.Report          `json:"coverage,omitempty"`
+	Execution      *sandbox.Report           `json:"execution,omitempty"`
+	Violations     []fileset.Violation       `json:"violations,omitempty"`
+	SecretsFound   int                       `json:"secretsFound"`
+	Cached         bool                      `json:"cached"`
+}
+
+func (r *GenerationResponse) FilePaths() []string {
+	paths := make([]string, 0, len(r.Files))
+	for _, file := range r.Files {
+		paths = append(paths, file.FilePath)
+	}
+	return paths
+}
+
+func (r *GenerationResponse) Contents() []string {
+	contents := make([]string, 0, len(r.Files))
+	for _, file := range r.Files {
+		contents = append(contents, file.Code)
+	}
+	return contents
+}
+
+type LanguagesResponse struct {
+	HttpResponse
+	Languages []languages.Language `json:"languages"`
+}
+
+func NewLanguagesResponse(registered []languages.Language) LanguagesResponse {
+	return LanguagesResponse{
+		HttpResponse: HttpResponse{}.Zero(),
+		Languages:    registered,
+	}
+}
+
+type HealthStatus string
+
+const (
+	Healthy  HealthStatus = "healthy"
+	Degraded HealthStatus = "degraded"
+)
+
+type HealthResponse struct {
+	HttpResponse
+	Status         HealthStatus `json:"status"`
+	CircuitBreaker any          `json:"circuitBreaker"`
+}
+
+func NewHealthResponse(status HealthStatus, circuitBreaker any) HealthResponse {
+	return HealthResponse{
+		HttpResponse:   HttpResponse{}.Zero(),
+		Status:         status,
+		CircuitBreaker: circuitBreaker,
+	}
+}
+
+// LivenessResponse only proves the process answers, Uptime is in seconds.
+type LivenessResponse struct {
+	HttpResponse
+	Status string  `json:"status"`
+	Uptime float64 `json:"uptime"`
+}
+
+func NewLivenessResponse(started time.Time) LivenessResponse {
+	return LivenessResponse{
+		HttpResponse: HttpResponse{}.Zero(),
+		Status:       "alive",
+		Uptime:       time.Since(started).Seconds(),
+	}
+}
+
+type ReadinessResponse struct {
+	HttpResponse
+	Ready  bool            `json:"ready"`
+	Checks []health.Result `json:"checks"`
+}
+
+func NewReadinessResponse(ready bool, checks []health.Result) ReadinessResponse {
+	return ReadinessResponse{
+		HttpResponse: HttpResponse{}.Zero(),
+		Ready:        ready,
+		Checks:       checks,
+	}
+}
+
+// VersionResponse identifies what produces generations: the build, the
+// default model profile and prompt version, and the configuration.
+type VersionResponse struct {
+	HttpResponse
+	Build         buildinfo.Info `json:"build"`
+	Profile       string         `json:"profile"`
+	Model         string         `json:"model"`
+	PromptVersion string         `json:"promptVersion"`
+	Config        config.Version `json:"config"`
+}
+
+type ConfigResponse struct {
+	HttpResponse
+	Version config.Version `json:"version"`
+	Config  *config.Config `json:"config"`
+}
+
+func NewConfigResponse(version config.Version, config *config.Config) ConfigResponse {
+	return ConfigResponse{
+		HttpResponse: HttpResponse{}.Zero(),
+		Version:      version,
+		Config:       config,
+	}
+}
+
+type ModelProfile struct {
+	Name            string  `json:"name"`
+	Model           string  `json:"model"`
+	Location        string  `json:"location"`
+	Temperature     float32 `json:"temperature"`
+	MaxOutputTokens int32   `json:"maxOutputTokens"`
+	Default         bool    `json:"default"`
+}
+
+type ProfilesResponse struct {
+	HttpResponse
+	Profiles []ModelProfile `json:"profiles"`
+}
+
+func NewProfilesResponse(vertex config.VertexAIConfig) ProfilesResponse {
+	profiles := vertex.ModelProfiles()
+	defaultProfile := vertex.DefaultProfileName()
+
+	response := ProfilesResponse{
+		HttpResponse: HttpResponse{}.Zero(),
+		Profiles:     make([]ModelProfile, 0, len(profiles)),
+	}
+
+	for _, name := range vertex.ProfileNames() {
+		profile := profiles[name]
+		response.Profiles = append(response.Profiles, ModelProfile{
+			Name:            name,
+			Model:           profile.Name,
+			Location:        profile.Location,
+			Temperature:     profile.Temperature,
+			MaxOutputTokens: profile.MaxOutputTokens,
+			Default:         name == defaultProfile,
+		})
+	}
+
+	return response
+}
+
+// QuotaUsage counts against the limits of the same name, a remaining value
+// of -1 means unlimited.
+type QuotaUsage struct {
+	RequestsPerMinute     int64 `json:"requestsPerMinute"`
+	ConcurrentGenerations int64 `json:"concurrentGenerations"`
+	DailyTokens           int64 `json:"dailyTokens"`
+}
+
+// QuotaScope is the usage of the quotas of one user or of all of them.
+type QuotaScope struct {
+	Limits    config.QuotaLimits `json:"limits"`
+	Used      QuotaUsage         `json:"used"`
+	Remaining QuotaUsage         `json:"remaining"`
+}
+
+// QuotaResponse holds the quotas of the user at the top level, a request
+// also needs the global ones to have some left.
+type QuotaResponse struct {
+	HttpResponse
+	User    string `json:"user"`
+	Enabled bool   `json:"enabled"`
+	QuotaScope
+	Global       QuotaScope `json:"global"`
+	MinuteResets time.Time  `json:"minuteResets"`
+	DayResets    time.Time  `json:"dayResets"`
 }
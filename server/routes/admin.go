@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"ai-test/config"
+	"ai-test/server/errors"
+	"ai-test/server/responses"
+	"net/http"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func activeConfig(c fiber.Ctx) {
+	response := responses.NewConfigResponse(config.ActiveVersion(), config.Get())
+
+	if err := c.Status(http.StatusOK).JSON(response); err != nil {
+		errors.InternalServerError.Send(c)
+	}
+}
@@ -0,0 +1,52 @@
+package routes
+
+import (
+	"ai-test/config"
+	"ai-test/identity"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const excludeCertificates = "exclude"
+
+// sandboxCertificates reads the configured sandbox certificates, keyed by the
+// path the generated client expects them at. When they are not bundled, the
+// returned reason explains why.
+func sandboxCertificates(id *identity.ClientIdentity) (map[string][]byte, string) {
+	conf := config.Get().Archive.Certificates
+
+	if !conf.Enabled {
+		return nil, "bundling certificates is disabled on this server"
+	}
+
+	if id == nil || id.Environment != identity.Sandbox || id.ClientId != identity.SandboxClientId {
+		return nil, "the example certificates only work with the sandbox example client"
+	}
+
+	certificate, err := os.ReadFile(filepath.Join(conf.Directory, conf.Certificate))
+	if err != nil {
+		return nil, fmt.Sprintf("the certificate could not be read on the server: %v", err)
+	}
+
+	key, err := os.ReadFile(filepath.Join(conf.Directory, conf.Key))
+	if err != nil {
+		return nil, fmt.Sprintf("the private key could not be read on the server: %v", err)
+	}
+
+	return map[string][]byte{
+		id.CertificatePath: certificate,
+		id.KeyPath:         key,
+	}, ""
+}
+
+func certificatesWarning(id identity.ClientIdentity, reason string) string {
+	return fmt.Sprintf(`
+
+## Certificates
+
+> **Warning:** the TLS certificates are not included in this archive (%s).
+> Place your client certificate at `+"`%s`"+` and its private key at `+"`%s`"+`
+> before running the application.
+`, reason, id.CertificatePath, id.KeyPath)
+}
@@ -1,45 +1,37 @@
 package routes
 
 import (
-	"ai-test/gemini"
+	"ai-test/auth"
+	"ai-test/metrics"
 	"ai-test/server/errors"
-	"ai-test/server/responses"
 	"ai-test/util"
 	"ai-test/util/level"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/log"
 )
 
 type ChatPrompt struct {
-	Prompt string `json:"prompt"`
+	Prompt  string `json:"prompt"`
+	Profile string `json:"profile"`
 }
 
 func startChat(c fiber.Ctx) {
-	if geminiClient == nil {
-		geminiClient = gemini.NewClient()
-	}
+	log.Infof("Starting chat session for %s", auth.UserOf(c))
 
-	if httpErr := geminiClient.StartChatSession(); httpErr != nil {
+	if httpErr := geminiClient().StartChatSession(c.Context(), c.Query("profile")); httpErr != nil {
 		httpErr.Send(c)
 		return
 	}
 
+	metrics.ChatActivity(auth.UserOf(c).String())
+
 	if err := c.Status(200).SendString("Chat session started"); err != nil {
 		errors.InternalServerError.Send(c)
 	}
 }
 
 func chat(c fiber.Ctx) {
-	if geminiClient == nil {
-		herr := &errors.HttpError{
-			HttpResponse: responses.HttpResponse{}.Zero(),
-			Code:         400,
-			Message:      "Chat must be initialized first",
-		}
-		herr.Send(c)
-		return
-	}
-
 	chatPrompt := new(ChatPrompt)
 	if err := c.Bind().JSON(chatPrompt); err != nil {
 		util.HandleError(err.Error(), err, level.WARN)
@@ -47,12 +39,20 @@ func chat(c fiber.Ctx) {
 		return
 	}
 
-	response, herr := geminiClient.SendMessage(chatPrompt.Prompt)
+	ctx, _, finish, ok := startJob(c, "")
+	if !ok {
+		return
+	}
+	defer finish()
+
+	response, herr := geminiClient().SendMessage(ctx, chatPrompt.Profile, chatPrompt.Prompt)
 	if herr != nil {
 		herr.Send(c)
 		return
 	}
 
+	metrics.ChatActivity(auth.UserOf(c).String())
+
 	if err := c.Status(200).JSON(response); err != nil {
 		errors.InternalServerError.Send(c)
 	}
@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// disconnectPoll is how often a running job checks whether its client is
+// still connected.
+const disconnectPoll = 500 * time.Millisecond
+
+// connectionContext derives a context from the request of c that is cancelled
+// once the client hangs up. fasthttp only cancels request contexts on server
+// shutdown, so without it an abandoned request runs its model calls to the
+// end. The returned cancel function must be called before the handler ends.
+func connectionContext(c fiber.Ctx) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(c.Context())
+
+	conn := c.RequestCtx().Conn()
+	if tlsConn, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	go func() {
+		ticker := time.NewTicker(disconnectPoll)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if ctx.Err() == nil && hungUp(conn) {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return ctx, cancel
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package routes
+
+import "net"
+
+// Without a non-consuming peek a hang up is not detected, jobs then only stop
+// when cancelled explicitly.
+func hungUp(net.Conn) bool {
+	return false
+}
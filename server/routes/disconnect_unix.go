@@ -0,0 +1,32 @@
+//go:build unix
+
+package routes
+
+import (
+	"net"
+	"syscall"
+)
+
+// hungUp peeks at conn without consuming anything, an end of stream means
+// the client closed the connection.
+func hungUp(conn net.Conn) bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	closed := false
+	err = raw.Read(func(fd uintptr) bool {
+		var buf [1]byte
+		n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		closed = n == 0 && err == nil
+		return true
+	})
+
+	return err == nil && closed
+}
@@ -1,13 +1,20 @@
 package routes
 
 import (
+	"ai-test/auth"
+	"ai-test/config"
+	"ai-test/fileset"
+	"ai-test/gemini"
+	"ai-test/identity"
+	"ai-test/secrets"
+	"ai-test/store"
 	"archive/zip"
 	"bytes"
 	"encoding/json"
 	"errors"
-	"html"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -17,8 +24,9 @@ type FileEntry struct {
 }
 
 type Payload struct {
-	Time  string      `json:"time"`
-	Files []FileEntry `json:"files"`
+	Time           string                   `json:"time"`
+	Files          []FileEntry              `json:"files"`
+	ClientIdentity *identity.ClientIdentity `json:"clientIdentity"`
 }
 
 // GET /api/download
@@ -28,31 +36,76 @@ func generateFilesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse JSON from memory
+	// The last generation of the caller, whichever process generated it.
+	generation, err := gemini.LastGeneration(auth.FromRequest(r))
+	if errors.Is(err, store.ErrNotFound) {
+		httpErrorJSON(w, http.StatusNotFound, "nothing was generated yet")
+		return
+	}
+	if err != nil {
+		httpErrorJSON(w, http.StatusInternalServerError, "failed loading generation: "+err.Error())
+		return
+	}
+
+	generated, _ := json.Marshal(generation.Response)
+
 	var payload Payload
-	if err := json.Unmarshal(GeneratedJSON, &payload); err != nil {
+	if err := json.Unmarshal(generated, &payload); err != nil {
 		httpErrorJSON(w, http.StatusBadRequest, "invalid global JSON: "+err.Error())
 		return
 	}
 
+	clientIdentity := identity.ClientIdentity{}.Resolved()
+	if payload.ClientIdentity != nil {
+		clientIdentity = *payload.ClientIdentity
+	}
+
+	// Certificates come from the server only, whatever the model put at
+	// their paths is dropped.
+	certificates, omitted := sandboxCertificates(payload.ClientIdentity)
+	if r.URL.Query().Get("certificates") == excludeCertificates {
+		certificates, omitted = nil, "they were excluded from the download"
+	}
+
+	// Generated files may have been returned unredacted, the archive is
+	// redacted when the download asks for it or the configuration always does.
+	secretsConf := config.Get().Secrets
+	redact := gemini.ShouldRedact(secretsConf, r.URL.Query().Get("secrets"))
+	scanner := secrets.NewScanner(append([]string{clientIdentity.ClientId}, secretsConf.Allowed...)...)
+
+	readme := readmePath(payload.Files)
+	if certificates == nil && readme == "" {
+		readme = "README.md"
+		payload.Files = append(payload.Files, FileEntry{FilePath: readme, Code: "# Generated project"})
+	}
+
 	// In‑memory ZIP buffer
 	buf := new(bytes.Buffer)
 	zipWriter := zip.NewWriter(buf)
 
 	for _, fe := range payload.Files {
 
-		// Sanitize and normalize paths
-		cleanRel, err := sanitizeRelativePath(fe.FilePath)
+		// Paths are normalized at generation time already, this only guards
+		// against payloads from before that.
+		zipPath, err := fileset.CleanPath(fe.FilePath)
 		if err != nil {
 			httpErrorJSON(w, http.StatusBadRequest, "invalid filePath: "+err.Error())
 			return
 		}
 
-		// Ensure directories inside ZIP
-		zipPath := filepath.ToSlash(cleanRel)
+		if zipPath == clientIdentity.CertificatePath || zipPath == clientIdentity.KeyPath {
+			continue
+		}
+
+		code := fe.Code
 
-		// HTML decode code (because your JSON contains &lt; etc.)
-		code := html.UnescapeString(fe.Code)
+		if redact {
+			code = secrets.Redact(code, scanner.Scan(code))
+		}
+
+		if certificates == nil && fe.FilePath == readme {
+			code += certificatesWarning(clientIdentity, omitted)
+		}
 
 		// Create file inside the ZIP
 		f, err := zipWriter.Create(zipPath)
@@ -68,6 +121,21 @@ func generateFilesHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	for _, path := range []string{clientIdentity.CertificatePath, clientIdentity.KeyPath} {
+		if certificates == nil {
+			break
+		}
+
+		f, err := zipWriter.Create(path)
+		if err == nil {
+			_, err = f.Write(certificates[path])
+		}
+		if err != nil {
+			httpErrorJSON(w, http.StatusInternalServerError, "failed adding certificate: "+err.Error())
+			return
+		}
+	}
+
 	// Finalize zip
 	if err := zipWriter.Close(); err != nil {
 		httpErrorJSON(w, http.StatusInternalServerError, "failed finalizing zip: "+err.Error())
@@ -77,7 +145,7 @@ func generateFilesHandler(w http.ResponseWriter, r *http.Request) {
 	// Send as downloadable file
 	w.Header().Set("Content-Type", "application/zip")
 	w.Header().Set("Content-Disposition", "attachment; filename=generated_project.zip")
-	w.Header().Set("Content-Length", string(len(buf.Bytes())))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(buf.Bytes())
@@ -85,20 +153,20 @@ func generateFilesHandler(w http.ResponseWriter, r *http.Request) {
 
 // --- HELPERS -------------------------------------------------------
 
-func sanitizeRelativePath(p string) (string, error) {
-	p = strings.TrimSpace(p)
-	p = filepath.ToSlash(p)
-
-	if strings.HasPrefix(p, "/") || strings.HasPrefix(p, "\\") {
-		return "", errors.New("absolute paths not allowed")
-	}
-	p = strings.TrimPrefix(p, "./")
-	p = filepath.Clean(p)
+// readmePath picks the README closest to the project root.
+func readmePath(files []FileEntry) string {
+	readme := ""
+	for _, fe := range files {
+		path := filepath.ToSlash(strings.TrimSpace(fe.FilePath))
+		if !strings.EqualFold(filepath.Base(path), "README.md") {
+			continue
+		}
 
-	if p == ".." || strings.HasPrefix(p, "../") || strings.Contains(p, "/../") {
-		return "", errors.New("path traversal not allowed")
+		if readme == "" || strings.Count(path, "/") < strings.Count(readme, "/") {
+			readme = fe.FilePath
+		}
 	}
-	return filepath.FromSlash(p), nil
+	return readme
 }
 
 func httpErrorJSON(w http.ResponseWriter, status int, msg string) {
@@ -1,59 +1,134 @@
 package routes
 
 import (
+	"ai-test/auth"
 	"ai-test/gemini"
+	"ai-test/jobs"
+	"ai-test/languages"
 	"ai-test/server/errors"
 	"ai-test/server/responses"
-	"encoding/json"
+	"ai-test/util"
+	"ai-test/util/level"
+	"context"
+	stderrors "errors"
 	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/log"
 )
 
-var geminiClient *gemini.Client
-var GeneratedJSON []byte
-
-type PromptQuery struct {
-	Prompt string `query:"prompt"`
-}
+// geminiClient is created on first use. It holds no state of its own, that
+// lives in the shared store.
+var geminiClient = sync.OnceValue(gemini.NewClient)
 
 func generateCode(c fiber.Ctx) {
-	if geminiClient == nil {
-		geminiClient = gemini.NewClient()
-	}
-
-	q := new(PromptQuery)
+	q := new(gemini.GenerationRequest)
 
 	if err := c.Bind().Query(q); err != nil {
 		errors.BadRequestError.Send(c)
 		return
 	}
 
-	log.Info(q.Prompt)
+	if strings.TrimSpace(q.Prompt) == "" && (q.Language == "" || q.Api == "") {
+		errors.BadRequestError.Send(c)
+		return
+	}
+
+	if _, ok := languages.Get(q.Language); q.Language != "" && !ok {
+		errors.UnsupportedLanguageError.Send(c)
+		return
+	}
+
+	if problems := q.Identity().Validate(); len(problems) > 0 {
+		errors.InvalidIdentityError.WithDetails(problems...).Send(c)
+		return
+	}
+
+	user := auth.UserOf(c)
+
+	ctx, job, finish, ok := startJob(c, q.JobId)
+	if !ok {
+		return
+	}
+	defer finish()
+
+	log.Infof("Job %s for %s: %s", job.Id, user, q.BuildPrompt())
 
-	generatedCode, httpError := geminiClient.RunCodeGenerationPrompt(q.Prompt)
+	generatedCode, httpError := geminiClient().GenerateCode(ctx, *q)
 	if httpError != nil {
 		httpError.Send(c)
 		return
 	}
 
-	GeneratedJSON, _ = json.Marshal(generatedCode)
+	generatedCode.JobId = job.Id
+	generatedCode.User = user.String()
 
 	if err := c.Status(http.StatusOK).JSON(generatedCode); err != nil {
 		errors.InternalServerError.Send(c)
 	}
+}
+
+// startJob registers the model work of a request as a job, so it can be
+// cancelled and is waited for on shutdown. The job is also cancelled when the
+// client disconnects. It sends the error response when the job could not be
+// started.
+func startJob(c fiber.Ctx, id string) (context.Context, *jobs.Job, func(), bool) {
+	parent, stopWatching := connectionContext(c)
+
+	ctx, job, finish, err := jobs.Start(parent, id, auth.UserOf(c).String())
+	if err != nil {
+		stopWatching()
+	}
 
-	geminiClient.Status = responses.Done
+	switch {
+	case err == nil:
+		c.Set("X-Job-Id", job.Id)
+		return ctx, job, func() {
+			finish()
+			stopWatching()
+		}, true
+	case stderrors.Is(err, jobs.ErrDuplicateJob):
+		errors.JobConflictError.Send(c)
+	case stderrors.Is(err, jobs.ErrDraining):
+		errors.ShuttingDownError.Send(c)
+	default:
+		util.HandleError("Could not start job: %v", err, level.ERROR)
+		errors.InternalServerError.Send(c)
+	}
+	return nil, nil, nil, false
 }
 
 func generationStatus(c fiber.Ctx) {
-	if geminiClient == nil {
-		c.Status(http.StatusOK).JSON(responses.NewGenerationStatusResponse(responses.NotStarted))
+	status, err := gemini.Status(auth.UserOf(c), c.Query("jobId"))
+	if err != nil {
+		util.HandleError("Could not read generation status: %v", err, level.ERROR)
+		errors.InternalServerError.Send(c)
 		return
 	}
 
-	if err := c.Status(http.StatusOK).JSON(responses.NewGenerationStatusResponse(geminiClient.Status)); err != nil {
+	if err := c.Status(http.StatusOK).JSON(responses.NewGenerationStatusResponse(status)); err != nil {
 		errors.InternalServerError.Send(c)
 	}
 }
+
+func cancelJob(c fiber.Ctx) {
+	user := auth.UserOf(c)
+
+	// Only the owner of a job and administrators may cancel it.
+	cancelled, err := jobs.Cancel(c.Params("id"), func(owner string) bool {
+		return user.Admin || owner == user.String()
+	})
+	if err != nil {
+		util.HandleError("Could not cancel job: %v", err, level.ERROR)
+		errors.InternalServerError.Send(c)
+		return
+	}
+	if !cancelled {
+		errors.JobNotFoundError.Send(c)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"ai-test/gemini"
+	"ai-test/server/errors"
+	"ai-test/server/responses"
+	"net/http"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func healthStatus(c fiber.Ctx) {
+	breaker := gemini.Breaker()
+
+	status := responses.Healthy
+	if breaker.State != gemini.BreakerClosed {
+		status = responses.Degraded
+	}
+
+	if err := c.Status(http.StatusOK).JSON(responses.NewHealthResponse(status, breaker)); err != nil {
+		errors.InternalServerError.Send(c)
+	}
+}
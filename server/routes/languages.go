@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"ai-test/languages"
+	"ai-test/server/errors"
+	"ai-test/server/responses"
+	"net/http"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func listLanguages(c fiber.Ctx) {
+	response := responses.NewLanguagesResponse(languages.All())
+
+	if err := c.Status(http.StatusOK).JSON(response); err != nil {
+		errors.InternalServerError.Send(c)
+	}
+}
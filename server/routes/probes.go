@@ -0,0 +1,82 @@
+package routes
+
+import (
+	"ai-test/buildinfo"
+	"ai-test/config"
+	"ai-test/health"
+	"ai-test/metrics"
+	"ai-test/server/errors"
+	"ai-test/server/responses"
+	"ai-test/util"
+	"ai-test/util/level"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+var started = time.Now()
+
+// Creating the provider client needs credentials, without them the server
+// runs but cannot generate anything.
+func init() {
+	health.Register(health.Func("provider", func(context.Context) error {
+		return geminiClient().Ready()
+	}))
+}
+
+// GET /healthz
+func liveness(c fiber.Ctx) {
+	if err := c.Status(http.StatusOK).JSON(responses.NewLivenessResponse(started)); err != nil {
+		errors.InternalServerError.Send(c)
+	}
+}
+
+// GET /readyz
+func readiness(c fiber.Ctx) {
+	ready, checks := health.Check(c.Context())
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	if err := c.Status(status).JSON(responses.NewReadinessResponse(ready, checks)); err != nil {
+		errors.InternalServerError.Send(c)
+	}
+}
+
+// GET /metrics
+func prometheusMetrics(c fiber.Ctx) {
+	body, contentType, err := metrics.Expose(c.Get(fiber.HeaderAccept))
+	if err != nil {
+		util.HandleError("Could not gather metrics: %v", err, level.ERROR)
+		errors.InternalServerError.Send(c)
+		return
+	}
+
+	c.Set(fiber.HeaderContentType, contentType)
+	if err := c.Status(http.StatusOK).Send(body); err != nil {
+		errors.InternalServerError.Send(c)
+	}
+}
+
+// GET /api/version
+func version(c fiber.Ctx) {
+	conf := config.Get()
+	profile, model, _ := conf.Vertex.Profile("")
+
+	response := responses.VersionResponse{
+		HttpResponse:  responses.HttpResponse{}.Zero(),
+		Build:         buildinfo.Get(),
+		Profile:       profile,
+		Model:         model.Name,
+		PromptVersion: conf.Prompts.Default,
+		Config:        config.ActiveVersion(),
+	}
+
+	if err := c.Status(http.StatusOK).JSON(response); err != nil {
+		errors.InternalServerError.Send(c)
+	}
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"ai-test/auth"
+	"ai-test/quota"
+	"ai-test/server/errors"
+	"ai-test/util"
+	"ai-test/util/level"
+	"net/http"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func quotaStatus(c fiber.Ctx) {
+	response, err := quota.Status(auth.UserOf(c))
+	if err != nil {
+		util.HandleError("Could not read quota: %v", err, level.ERROR)
+		errors.InternalServerError.Send(c)
+		return
+	}
+
+	if err := c.Status(http.StatusOK).JSON(response); err != nil {
+		errors.InternalServerError.Send(c)
+	}
+}
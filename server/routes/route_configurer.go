@@ -1,15 +1,38 @@
 package routes
 
-import "github.com/gofiber/fiber/v3"
+import (
+	"ai-test/auth"
+	"ai-test/quota"
+
+	"github.com/gofiber/fiber/v3"
+)
 
 func ConfigureRoutes(group *fiber.Router) {
 	generateGroup := (*group).Group("generate")
 	chatGroup := (*group).Group("chat")
+	adminGroup := (*group).Group("admin", auth.RequireAdmin())
 
-	generateGroup.Get("/code", generateCode)
+	generateGroup.Get("/code", quota.Limit(), quota.Concurrency(), generateCode)
 	generateGroup.Get("/status", generationStatus)
 	generateGroup.Get("/archive", generateFilesHandler)
+	generateGroup.Delete("/jobs/:id", cancelJob)
+
+	chatGroup.Post("/start", quota.Limit(), startChat)
+	chatGroup.Post("/message", quota.Limit(), chat)
+
+	adminGroup.Get("/config", activeConfig)
+
+	(*group).Get("/health", healthStatus)
+	(*group).Get("/profiles", listProfiles)
+	(*group).Get("/languages", listLanguages)
+	(*group).Get("/quota", quotaStatus)
+	(*group).Get("/version", version)
+}
 
-	chatGroup.Post("/start", startChat)
-	chatGroup.Post("/message", chat)
+// ConfigureProbes adds the liveness and readiness probes and the Prometheus
+// metrics, outside of the API and its authentication.
+func ConfigureProbes(router fiber.Router) {
+	router.Get("/healthz", liveness)
+	router.Get("/readyz", readiness)
+	router.Get("/metrics", prometheusMetrics)
 }
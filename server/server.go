@@ -1,37 +1,80 @@
 package server
 
 import (
+	"ai-test/auth"
+	"ai-test/config"
+	"ai-test/metrics"
+	"ai-test/server/errors"
 	"ai-test/server/routes"
 	"ai-test/util"
 	"ai-test/util/level"
+	"context"
 	"os"
+	"path/filepath"
+	"runtime/debug"
 	"strconv"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/gofiber/fiber/v3/middleware/recover"
 	"github.com/gofiber/fiber/v3/middleware/static"
 )
 
 var app = fiber.New(fiber.Config{
 	ServerHeader: "Fiber 3.0",
 	AppName:      "ING AI Sandbox Playground",
+	ErrorHandler: errors.Handler,
 })
 
-func StartServer(port int) {
-	api := app.Group("/api")
+func StartServer(conf config.ServerConfig) {
+	app.Use(metrics.Middleware())
+
+	// A panic in a handler must never take down a prefork child.
+	app.Use(recover.New(recover.Config{
+		EnableStackTrace: true,
+		StackTraceHandler: func(c fiber.Ctx, e any) {
+			log.Errorf("Recovered from panic in %s %s: %v\n%s", c.Method(), c.Path(), e, debug.Stack())
+		},
+	}))
+
+	routes.ConfigureProbes(app)
+
+	api := app.Group("/api", auth.New("/api/health", "/api/version"))
 	routes.ConfigureRoutes(&api)
 
 	app.Use("/", static.New("", static.Config{
-		FS: os.DirFS("./frontend/dist"),
+		FS: os.DirFS(conf.StaticDir),
 	}))
 
 	app.Get("/*", func(c fiber.Ctx) error {
-		return c.SendFile("./frontend/dist/index.html")
+		return c.SendFile(filepath.Join(conf.StaticDir, "index.html"))
 	})
 
-	err := app.Listen(":"+strconv.Itoa(port), fiber.ListenConfig{
-		EnablePrefork:     true,
+	stopping := make(chan struct{})
+	stopped := make(chan struct{})
+	go awaitSignal(conf, stopping, stopped)
+
+	if conf.Prefork && !fiber.IsChild() {
+		trackChildren()
+		defer forgetChildren()
+	}
+
+	publishing, stopPublishing := context.WithCancel(context.Background())
+	defer stopPublishing()
+	go metrics.Publish(publishing)
+
+	err := app.Listen(":"+strconv.Itoa(conf.Port), fiber.ListenConfig{
+		EnablePrefork:     conf.Prefork,
 		EnablePrintRoutes: true,
 	})
 
-	util.HandleError("Couldn't start server", err, level.FATAL)
+	select {
+	case <-stopping:
+		// Listen returns once the listener is closed, before the running
+		// requests are drained.
+		<-stopped
+		log.Info("Server stopped")
+	default:
+		util.HandleError("Couldn't start server", err, level.FATAL)
+	}
 }
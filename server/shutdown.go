@@ -0,0 +1,145 @@
+package server
+
+import (
+	"ai-test/config"
+	"ai-test/jobs"
+	"ai-test/metrics"
+	"ai-test/store"
+	"ai-test/util"
+	"ai-test/util/level"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	// shutdownGrace is left to Fiber to finish the responses of the jobs
+	// that were waited for or cancelled.
+	shutdownGrace = 5 * time.Second
+	siblingPoll   = 200 * time.Millisecond
+)
+
+var (
+	childrenMu sync.Mutex
+	// children are the processes forked by the master of a prefork server.
+	children []int
+)
+
+// preforkKey names state of the prefork server whose master has the given
+// pid, which its children know as their parent.
+func preforkKey(master int, name string) string {
+	return fmt.Sprintf("prefork:%d:%s", master, name)
+}
+
+func trackChildren() {
+	app.Hooks().OnFork(func(pid int) error {
+		childrenMu.Lock()
+		children = append(children, pid)
+		childrenMu.Unlock()
+
+		_, err := store.Shared().Increment(preforkKey(os.Getpid(), "children"), 1, 0)
+		util.HandleError("Could not register prefork child: %v", err, level.ERROR)
+		return nil
+	})
+}
+
+// awaitSignal shuts the server down on SIGINT or SIGTERM. It closes stopping
+// once a signal arrived and stopped once the shutdown is over.
+func awaitSignal(conf config.ServerConfig, stopping chan<- struct{}, stopped chan<- struct{}) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	sig := <-signals
+	close(stopping)
+	defer close(stopped)
+
+	log.Infof("Received %v, shutting down", sig)
+
+	if conf.Prefork && !fiber.IsChild() {
+		stopChildren()
+		return
+	}
+
+	shutdown(conf)
+}
+
+// stopChildren passes the signal on to the children, which drain on their
+// own. Platforms only signal the master.
+func stopChildren() {
+	childrenMu.Lock()
+	defer childrenMu.Unlock()
+
+	for _, pid := range children {
+		process, err := os.FindProcess(pid)
+		if err == nil {
+			err = process.Signal(syscall.SIGTERM)
+		}
+		util.HandleError("Could not stop prefork child: %v", err, level.WARN)
+	}
+}
+
+// shutdown stops new jobs, waits for the running ones up to the configured
+// timeout, cancelling what is left after it, and then stops Fiber. Finished
+// jobs have saved their results to the store by then.
+func shutdown(conf config.ServerConfig) {
+	deadline := time.Now().Add(conf.ShutdownTimeout + shutdownGrace)
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancel()
+
+	if left := jobs.Drain(ctx); left > 0 {
+		log.Warnf("Cancelling %d jobs still running after %v", left, conf.ShutdownTimeout)
+		jobs.CancelAll()
+	}
+
+	err := app.ShutdownWithTimeout(shutdownGrace)
+	util.HandleError("Could not shut down the server cleanly: %v", err, level.WARN)
+
+	if fiber.IsChild() {
+		awaitSiblings(deadline)
+	}
+}
+
+// awaitSiblings keeps a prefork child alive until every child drained, or
+// until deadline. The master kills the remaining children as soon as the
+// first one exits.
+func awaitSiblings(deadline time.Time) {
+	shared := store.Shared()
+	master := os.Getppid()
+
+	done, err := shared.Increment(preforkKey(master, "stopped"), 1, time.Hour)
+	if err != nil {
+		util.HandleError("Could not report prefork child as stopped: %v", err, level.ERROR)
+		return
+	}
+
+	for time.Now().Before(deadline) {
+		total, err := shared.Counter(preforkKey(master, "children"))
+		if err != nil || done >= total {
+			return
+		}
+
+		time.Sleep(siblingPoll)
+
+		if done, err = shared.Counter(preforkKey(master, "stopped")); err != nil {
+			return
+		}
+	}
+}
+
+// forgetChildren removes the state of the prefork server this process is the
+// master of.
+func forgetChildren() {
+	for _, name := range []string{"children", "stopped"} {
+		err := store.Shared().Delete(preforkKey(os.Getpid(), name))
+		util.HandleError("Could not clean up prefork state: %v", err, level.WARN)
+	}
+	metrics.Forget()
+}
@@ -0,0 +1,260 @@
+package store
+
+import (
+	"ai-test/util"
+	"ai-test/util/level"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore keeps every key in its own file of a directory, guarded by an
+// advisory lock, which shares the state between the processes on a host.
+// Files of deleted and expired keys are removed.
+type FileStore struct {
+	dir string
+
+	mu       sync.Mutex
+	sweeping bool
+	swept    time.Time
+}
+
+func NewFileStore(dir string) *FileStore {
+	err := os.MkdirAll(dir, 0o755)
+	util.HandleError("Could not create store directory: %v", err, level.ERROR)
+
+	return &FileStore{dir: dir}
+}
+
+type record struct {
+	Counter int64     `json:"counter,omitempty"`
+	Value   []byte    `json:"value,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+func (s *FileStore) Increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	var value int64
+
+	err := s.update(key, func(r *record) *record {
+		if r == nil {
+			r = &record{Expires: expiry(ttl)}
+		}
+
+		r.Counter += delta
+		value = r.Counter
+		return r
+	})
+
+	return value, err
+}
+
+func (s *FileStore) Counter(key string) (int64, error) {
+	r, err := s.read(key)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return r.Counter, nil
+}
+
+func (s *FileStore) Get(key string) ([]byte, error) {
+	r, err := s.read(key)
+	if err != nil {
+		return nil, err
+	}
+	return r.Value, nil
+}
+
+func (s *FileStore) Set(key string, value []byte, ttl time.Duration) error {
+	return s.update(key, func(*record) *record {
+		return &record{Value: value, Expires: expiry(ttl)}
+	})
+}
+
+func (s *FileStore) Add(key string, value []byte, ttl time.Duration) (bool, error) {
+	added := false
+
+	err := s.update(key, func(r *record) *record {
+		if r != nil {
+			return r
+		}
+
+		added = true
+		return &record{Value: value, Expires: expiry(ttl)}
+	})
+
+	return added, err
+}
+
+func (s *FileStore) Delete(key string) error {
+	err := s.locked(s.path(key), os.O_RDWR, func(*os.File) (bool, error) {
+		return true, nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// read returns the live record of key. Writers replace the content of a file
+// while holding its lock, so readers take it too.
+func (s *FileStore) read(key string) (*record, error) {
+	var found *record
+
+	err := s.locked(s.path(key), os.O_RDONLY, func(file *os.File) (bool, error) {
+		r, err := decode(file)
+		found = r
+		return err == nil && r == nil, err
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
+
+// update replaces the record of key by what change returns for its current
+// one, nil when there is none, while holding the lock of its file. Returning
+// nil deletes the record.
+func (s *FileStore) update(key string, change func(r *record) *record) error {
+	defer s.sweepIfDue()
+
+	return s.locked(s.path(key), os.O_RDWR|os.O_CREATE, func(file *os.File) (bool, error) {
+		r, err := decode(file)
+		if err != nil {
+			return false, err
+		}
+
+		r = change(r)
+		if r == nil {
+			return true, nil
+		}
+
+		data, err := json.Marshal(r)
+		if err != nil {
+			return false, err
+		}
+
+		if err := file.Truncate(0); err != nil {
+			return false, err
+		}
+		_, err = file.WriteAt(data, 0)
+		return false, err
+	})
+}
+
+// locked runs fn while holding the lock of the file at path, and removes the
+// file when fn asks for it. A file removed while waiting for its lock is
+// opened again, so whoever waited acts on the file now at path.
+func (s *FileStore) locked(path string, flag int, fn func(file *os.File) (remove bool, err error)) error {
+	for {
+		file, err := os.OpenFile(path, flag, 0o600)
+		if err != nil {
+			return err
+		}
+
+		if err := lock(file); err != nil {
+			file.Close()
+			return err
+		}
+
+		if !current(file, path) {
+			unlock(file)
+			file.Close()
+			continue
+		}
+
+		remove, err := fn(file)
+		if remove && err == nil {
+			err = os.Remove(path)
+		}
+
+		unlock(file)
+		file.Close()
+		return err
+	}
+}
+
+// current reports whether file is still the one at path.
+func current(file *os.File, path string) bool {
+	opened, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	found, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(opened, found)
+}
+
+// sweepIfDue starts removing the files of expired keys in the background,
+// at most once per sweepInterval.
+func (s *FileStore) sweepIfDue() {
+	s.mu.Lock()
+	due := !s.sweeping && time.Since(s.swept) >= sweepInterval
+	s.sweeping = s.sweeping || due
+	s.mu.Unlock()
+
+	if due {
+		go s.sweep()
+	}
+}
+
+// sweep removes the files of the keys that expired without being read again.
+func (s *FileStore) sweep() {
+	defer func() {
+		s.mu.Lock()
+		s.sweeping = false
+		s.swept = time.Now()
+		s.mu.Unlock()
+	}()
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		util.HandleError("Could not sweep store directory: %v", err, level.WARN)
+		return
+	}
+
+	for _, path := range paths {
+		err := s.locked(path, os.O_RDONLY, func(file *os.File) (bool, error) {
+			r, err := decode(file)
+			return err == nil && r == nil, err
+		})
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			util.HandleError("Could not sweep store file: %v", err, level.WARN)
+		}
+	}
+}
+
+// decode reads the record in file, nil when it is empty or expired.
+func decode(file *os.File) (*record, error) {
+	data, err := io.ReadAll(file)
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+
+	r := &record{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	if expired(r.Expires) {
+		return nil, nil
+	}
+	return r, nil
+}
+
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, fileName(key)+".json")
+}
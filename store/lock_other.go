@@ -0,0 +1,21 @@
+//go:build !unix
+
+package store
+
+import (
+	"os"
+	"sync"
+)
+
+// Without flock the file store is only safe within one process.
+var fileLock sync.Mutex
+
+func lock(*os.File) error {
+	fileLock.Lock()
+	return nil
+}
+
+func unlock(*os.File) error {
+	fileLock.Unlock()
+	return nil
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	counter int64
+	value   []byte
+	expires time.Time
+}
+
+// MemoryStore keeps the state in this process only, it is not shared between
+// prefork children.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	swept   time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]entry{}}
+}
+
+// entry returns the live entry at key, removing it once it expired.
+func (s *MemoryStore) entry(key string) (entry, bool) {
+	e, ok := s.entries[key]
+	if ok && expired(e.expires) {
+		delete(s.entries, key)
+		return entry{}, false
+	}
+	return e, ok
+}
+
+// put writes e at key and sweeps the expired entries when due, s.mu must be
+// held.
+func (s *MemoryStore) put(key string, e entry) {
+	s.entries[key] = e
+
+	if time.Since(s.swept) < sweepInterval {
+		return
+	}
+	for key, e := range s.entries {
+		if expired(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+	s.swept = time.Now()
+}
+
+func (s *MemoryStore) Increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entry(key)
+	if !ok {
+		e = entry{expires: expiry(ttl)}
+	}
+
+	e.counter += delta
+	s.put(key, e)
+	return e.counter, nil
+}
+
+func (s *MemoryStore) Counter(key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, _ := s.entry(key)
+	return e.counter, nil
+}
+
+func (s *MemoryStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entry(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(e.value), nil
+}
+
+func (s *MemoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.put(key, entry{value: bytes.Clone(value), expires: expiry(ttl)})
+	return nil
+}
+
+func (s *MemoryStore) Add(key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entry(key); ok {
+		return false, nil
+	}
+
+	s.put(key, entry{value: bytes.Clone(value), expires: expiry(ttl)})
+	return true, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
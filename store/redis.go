@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds every command, the store interface carries no context.
+const redisTimeout = 5 * time.Second
+
+// incrementScript sets the ttl of counters it creates only, like the other
+// stores do.
+var incrementScript = redis.NewScript(`
+local value = redis.call('INCRBY', KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return value`)
+
+// RedisStore keeps the state in a server speaking the Redis protocol, which
+// shares it between processes on any number of hosts.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore connects to redis://[user:password@]host:port[/database],
+// rediss:// connecting over TLS.
+func NewRedisStore(rawUrl string) (*RedisStore, error) {
+	options, err := redis.ParseURL(rawUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	options.DialTimeout = redisTimeout
+	options.ReadTimeout = redisTimeout
+	options.WriteTimeout = redisTimeout
+
+	return &RedisStore{client: redis.NewClient(options)}, nil
+}
+
+func (s *RedisStore) Increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	return incrementScript.Run(ctx, s.client, []string{key}, delta, milliseconds(ttl)).Int64()
+}
+
+func (s *RedisStore) Counter(key string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	value, err := s.client.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return value, err
+}
+
+func (s *RedisStore) Get(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	value, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (s *RedisStore) Set(key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	return s.client.Set(ctx, key, value, max(ttl, 0)).Err()
+}
+
+func (s *RedisStore) Add(key string, value []byte, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	return s.client.SetNX(ctx, key, value, max(ttl, 0)).Result()
+}
+
+func (s *RedisStore) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	return s.client.Del(ctx, key).Err()
+}
+
+func milliseconds(ttl time.Duration) int64 {
+	return max(ttl.Milliseconds(), 0)
+}
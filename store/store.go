@@ -0,0 +1,151 @@
+package store
+
+import (
+	"ai-test/config"
+	"ai-test/health"
+	"ai-test/util"
+	"ai-test/util/level"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	MemoryBackend = "memory"
+	FileBackend   = "file"
+	RedisBackend  = "redis"
+)
+
+// sweepInterval bounds how often expired keys that are never read again are
+// removed. Sweeps piggyback on writes.
+const sweepInterval = time.Minute
+
+var ErrNotFound = errors.New("key not found")
+
+// Store holds the state shared by the processes of the server, so that a
+// prefork server behaves like a single process. Implementations must be safe
+// for concurrent use, by goroutines and, except for the memory store, by
+// processes. Every key expires after the ttl it was last written with, zero
+// meaning never.
+type Store interface {
+	// Increment adds delta to the counter at key and returns its new value.
+	// Only a counter created by Increment takes ttl.
+	Increment(key string, delta int64, ttl time.Duration) (int64, error)
+	// Counter returns the value of a counter, zero when it does not exist.
+	Counter(key string) (int64, error)
+	// Get returns the value at key or ErrNotFound.
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	// Add sets key only if it does not exist yet and reports whether it did.
+	Add(key string, value []byte, ttl time.Duration) (bool, error)
+	Delete(key string) error
+}
+
+func init() {
+	health.Register(health.Func("store", ping))
+}
+
+// ping writes and reads back a key, which every backend needs to do to serve
+// requests.
+func ping(context.Context) error {
+	key := fmt.Sprintf("health:%d", os.Getpid())
+	value := []byte(time.Now().Format(time.RFC3339Nano))
+
+	if err := Shared().Set(key, value, time.Minute); err != nil {
+		return err
+	}
+
+	read, err := Shared().Get(key)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(read, value) {
+		return errors.New("store returned another value than was written")
+	}
+	return nil
+}
+
+// New builds the store selected by the configuration.
+func New(conf config.StoreConfig) (Store, error) {
+	switch conf.Backend {
+	case FileBackend:
+		return NewFileStore(conf.Directory), nil
+	case RedisBackend:
+		return NewRedisStore(conf.Url)
+	default:
+		return NewMemoryStore(), nil
+	}
+}
+
+var (
+	shared     Store
+	sharedErr  error
+	sharedOnce sync.Once
+)
+
+// Shared returns the store of this server, created from the configuration on
+// first use. Changing the store configuration requires a restart.
+func Shared() Store {
+	sharedOnce.Do(func() {
+		shared, sharedErr = New(config.Get().Store)
+		if sharedErr != nil {
+			util.HandleError("Could not create store: %v", sharedErr, level.ERROR)
+			shared = unavailable{sharedErr}
+		}
+	})
+	return shared
+}
+
+// GetJSON decodes the value at key into v.
+func GetJSON(s Store, key string, v any) error {
+	data, err := s.Get(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func SetJSON(s Store, key string, v any, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Set(key, data, ttl)
+}
+
+// unavailable stands in for a store that could not be created, so every use
+// of it reports why.
+type unavailable struct {
+	err error
+}
+
+func (u unavailable) Increment(string, int64, time.Duration) (int64, error) { return 0, u.err }
+func (u unavailable) Counter(string) (int64, error)                         { return 0, u.err }
+func (u unavailable) Get(string) ([]byte, error)                            { return nil, u.err }
+func (u unavailable) Set(string, []byte, time.Duration) error               { return u.err }
+func (u unavailable) Add(string, []byte, time.Duration) (bool, error)       { return false, u.err }
+func (u unavailable) Delete(string) error                                   { return u.err }
+
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func expired(expires time.Time) bool {
+	return !expires.IsZero() && time.Now().After(expires)
+}
+
+// fileName maps a key to a name safe for any file system.
+func fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
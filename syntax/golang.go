@@ -0,0 +1,122 @@
+package syntax
+
+import (
+	"fmt"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register(Func("go/parser", []string{".go"}, checkGo))
+	Register(Func("go.mod", []string{"go.mod"}, checkGoMod))
+}
+
+func checkGo(code string) []Diagnostic {
+	_, err := parser.ParseFile(token.NewFileSet(), "", code, parser.SkipObjectResolution)
+	if err == nil {
+		return nil
+	}
+
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		return []Diagnostic{{Message: err.Error()}}
+	}
+
+	diagnostics := make([]Diagnostic, len(list))
+	for i, e := range list {
+		diagnostics[i] = Diagnostic{Line: e.Pos.Line, Column: e.Pos.Column, Message: e.Msg}
+	}
+	return diagnostics
+}
+
+var goVersion = regexp.MustCompile(`^1(\.\d+){1,2}((rc|beta)\d+)?$`)
+
+var goModDirectives = map[string]bool{
+	"module":    true,
+	"go":        true,
+	"toolchain": true,
+	"godebug":   true,
+	"require":   true,
+	"exclude":   true,
+	"replace":   true,
+	"retract":   true,
+	"tool":      true,
+	"ignore":    true,
+}
+
+// checkGoMod checks the structure of a go.mod: known directives, balanced
+// blocks and the presence of the module and go lines.
+func checkGoMod(code string) []Diagnostic {
+	var diagnostics []Diagnostic
+	report := func(line int, format string, args ...any) {
+		diagnostics = append(diagnostics, Diagnostic{Line: line, Column: 1, Message: fmt.Sprintf(format, args...)})
+	}
+
+	block := ""
+	blockLine := 0
+	seen := map[string]bool{}
+
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		number := i + 1
+
+		if comment := strings.Index(line, "//"); comment >= 0 {
+			line = line[:comment]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if block != "" {
+			if fields[0] == ")" {
+				block = ""
+			}
+			continue
+		}
+
+		directive := fields[0]
+		if !goModDirectives[directive] {
+			report(number, "unknown directive: %s", directive)
+			continue
+		}
+		if len(fields) == 1 {
+			report(number, "%s requires an argument", directive)
+			continue
+		}
+		seen[directive] = true
+
+		if fields[1] == "(" {
+			block, blockLine = directive, number
+			continue
+		}
+
+		switch directive {
+		case "module":
+			if _, err := strconv.Unquote(fields[1]); strings.HasPrefix(fields[1], `"`) && err != nil {
+				report(number, "invalid quoted module path: %s", fields[1])
+			}
+		case "go":
+			if !goVersion.MatchString(fields[1]) {
+				report(number, "invalid go version %q: must match format 1.23.0", fields[1])
+			}
+		case "require":
+			if len(fields) < 3 {
+				report(number, "usage: require module/path v1.2.3")
+			}
+		}
+	}
+
+	if block != "" {
+		report(blockLine, "%s block is not closed", block)
+	}
+	if !seen["module"] {
+		report(1, "no module directive found")
+	}
+
+	return diagnostics
+}
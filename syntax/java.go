@@ -0,0 +1,12 @@
+package syntax
+
+import "regexp"
+
+func init() {
+	// google-java-format only needs to parse the file, javac would need the
+	// project's dependencies on the classpath.
+	Register(Command("google-java-format", []string{".java"},
+		matchLines(regexp.MustCompile(`(?m)^<stdin>:(?P<line>\d+):(?P<column>\d+): error: (?P<message>.*)$`)),
+		"-",
+	))
+}
@@ -0,0 +1,12 @@
+package syntax
+
+import "regexp"
+
+func init() {
+	// node --check reports the line of the error followed by the error
+	// itself a few lines below.
+	Register(Command("node", []string{".js", ".mjs", ".cjs"},
+		matchLines(regexp.MustCompile(`(?ms)^\S+:(?P<line>\d+)$.*?^(?P<message>SyntaxError: [^\n]*)$`)),
+		"--check", "{file}",
+	))
+}
@@ -0,0 +1,76 @@
+package syntax
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Manifests and configuration files are checked in-process whatever the
+// language, e.g. package.json, Cargo.toml and pom.xml.
+func init() {
+	Register(Func("encoding/json", []string{".json"}, checkJson))
+	Register(Func("go-toml", []string{".toml"}, checkToml))
+	Register(Func("encoding/xml", []string{".xml"}, checkXml))
+}
+
+func checkJson(code string) []Diagnostic {
+	decoder := json.NewDecoder(strings.NewReader(code))
+
+	var value any
+	err := decoder.Decode(&value)
+	if err == nil {
+		// A second value after the document is as invalid as a broken one.
+		if _, err = decoder.Token(); err == io.EOF {
+			return nil
+		}
+		line, column := position(code, int(decoder.InputOffset()))
+		return []Diagnostic{{Line: line, Column: column, Message: "unexpected content after the JSON value"}}
+	}
+
+	var syntaxError *json.SyntaxError
+	if errors.As(err, &syntaxError) {
+		line, column := position(code, int(syntaxError.Offset))
+		return []Diagnostic{{Line: line, Column: column, Message: syntaxError.Error()}}
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		line, column := position(code, len(code))
+		return []Diagnostic{{Line: line, Column: column, Message: "unexpected end of JSON input"}}
+	}
+	return []Diagnostic{{Message: err.Error()}}
+}
+
+func checkToml(code string) []Diagnostic {
+	var value map[string]any
+	err := toml.Unmarshal([]byte(code), &value)
+	if err == nil {
+		return nil
+	}
+
+	var decodeError *toml.DecodeError
+	if errors.As(err, &decodeError) {
+		line, column := decodeError.Position()
+		return []Diagnostic{{Line: line, Column: column, Message: decodeError.Error()}}
+	}
+	return []Diagnostic{{Message: err.Error()}}
+}
+
+func checkXml(code string) []Diagnostic {
+	decoder := xml.NewDecoder(strings.NewReader(code))
+
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			line, column := decoder.InputPos()
+			return []Diagnostic{{Line: line, Column: column, Message: err.Error()}}
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package syntax
+
+import "regexp"
+
+// pythonCheck parses the code with the ast module and prints a syntax error
+// as line:column: message.
+const pythonCheck = `import ast, sys
+try:
+    ast.parse(sys.stdin.read())
+except SyntaxError as e:
+    print(f"{e.lineno or 0}:{e.offset or 0}: {e.msg}")
+    sys.exit(1)`
+
+func init() {
+	Register(Command("python3", []string{".py"},
+		matchLines(regexp.MustCompile(`(?m)^(?P<line>\d+):(?P<column>\d+): (?P<message>.*)$`)),
+		"-c", pythonCheck,
+	))
+}
@@ -0,0 +1,12 @@
+package syntax
+
+import "regexp"
+
+func init() {
+	// rustfmt parses the code before formatting it, without needing the
+	// crate's dependencies.
+	Register(Command("rustfmt", []string{".rs"},
+		matchLines(regexp.MustCompile(`(?m)^error: (?P<message>.*)\n\s*--> <stdin>:(?P<line>\d+):(?P<column>\d+)`)),
+		"--edition", "2021", "--emit", "stdout",
+	))
+}
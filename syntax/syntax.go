@@ -0,0 +1,223 @@
+package syntax
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Diagnostic is a syntax error in a generated file. Line and Column are
+// 1-based, zero when the checker does not report them.
+type Diagnostic struct {
+	Checker string `json:"checker"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// Checker validates the syntax of the files it matches. Unlike a build it
+// only needs the file itself, not its dependencies.
+type Checker interface {
+	Name() string
+	Matches(filePath string) bool
+	// Available reports whether the checker can run on this machine.
+	Available() bool
+	Check(ctx context.Context, filePath string, code string) ([]Diagnostic, error)
+}
+
+var (
+	mu       sync.RWMutex
+	checkers []Checker
+)
+
+// Register adds a checker. Every available checker matching a file runs on
+// it.
+func Register(checker Checker) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	checkers = append(checkers, checker)
+}
+
+// Check runs the checkers matching a file. A checker that fails to run is
+// reported as a diagnostic too, since the file then went unchecked.
+func Check(ctx context.Context, filePath string, code string) []Diagnostic {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var diagnostics []Diagnostic
+	for _, checker := range checkers {
+		if !checker.Matches(filePath) || !checker.Available() {
+			continue
+		}
+
+		found, err := checker.Check(ctx, filePath, code)
+		if err != nil {
+			found = []Diagnostic{{Message: "could not run checker: " + err.Error()}}
+		}
+
+		for _, diagnostic := range found {
+			diagnostic.Checker = checker.Name()
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+
+	return diagnostics
+}
+
+// Func is an in-process checker for files with one of the extensions, or
+// one of the base names when they start without a dot.
+func Func(name string, patterns []string, check func(code string) []Diagnostic) Checker {
+	return &funcChecker{name: name, patterns: patterns, check: check}
+}
+
+type funcChecker struct {
+	name     string
+	patterns []string
+	check    func(code string) []Diagnostic
+}
+
+func (c *funcChecker) Name() string { return c.name }
+
+func (c *funcChecker) Matches(filePath string) bool { return matches(c.patterns, filePath) }
+
+func (c *funcChecker) Available() bool { return true }
+
+func (c *funcChecker) Check(_ context.Context, _ string, code string) ([]Diagnostic, error) {
+	return c.check(code), nil
+}
+
+// Command is an external checker running the named program, if it is on
+// PATH. The code is passed on stdin, or in a temporary file substituted for
+// the {file} placeholder in args. A non-zero exit status means the output
+// holds the diagnostics, which parse extracts. When parse returns nil the
+// whole output is reported instead.
+func Command(name string, patterns []string, parse func(output string) []Diagnostic, args ...string) Checker {
+	return &command{name: name, patterns: patterns, parse: parse, args: args}
+}
+
+type command struct {
+	name     string
+	patterns []string
+	parse    func(output string) []Diagnostic
+	args     []string
+
+	lookup sync.Once
+	path   string
+}
+
+func (c *command) Name() string { return c.name }
+
+func (c *command) Matches(filePath string) bool { return matches(c.patterns, filePath) }
+
+func (c *command) Available() bool {
+	c.lookup.Do(func() {
+		c.path, _ = exec.LookPath(c.name)
+	})
+	return c.path != ""
+}
+
+func (c *command) Check(ctx context.Context, filePath string, code string) ([]Diagnostic, error) {
+	args := slices.Clone(c.args)
+
+	var stdin *strings.Reader
+	if i := slices.Index(args, "{file}"); i >= 0 {
+		file, err := tempFile(filePath, code)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(file)
+
+		args[i] = file
+	} else {
+		stdin = strings.NewReader(code)
+	}
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.path, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && ctx.Err() == nil {
+		diagnostics := c.parse(output.String())
+		if diagnostics == nil {
+			diagnostics = []Diagnostic{{Message: strings.TrimSpace(output.String())}}
+		}
+		return diagnostics, nil
+	}
+	return nil, err
+}
+
+// tempFile writes code to a temporary file with the extension of filePath,
+// tools pick the dialect from it.
+func tempFile(filePath string, code string) (string, error) {
+	file, err := os.CreateTemp("", "syntax-*"+path.Ext(filePath))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(code); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+func matches(patterns []string, filePath string) bool {
+	base := strings.ToLower(path.Base(filePath))
+
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, ".") && path.Ext(base) == pattern || pattern == base {
+			return true
+		}
+	}
+	return false
+}
+
+// position converts a byte offset into a 1-based line and column.
+func position(code string, offset int) (int, int) {
+	offset = min(max(offset, 0), len(code))
+
+	before := code[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := offset - strings.LastIndex(before, "\n")
+	return line, column
+}
+
+// matchLines returns a parser for tool output holding one diagnostic per
+// line, with the named groups line, column and message.
+func matchLines(pattern *regexp.Regexp) func(output string) []Diagnostic {
+	return func(output string) []Diagnostic {
+		var diagnostics []Diagnostic
+		for _, match := range pattern.FindAllStringSubmatch(output, -1) {
+			diagnostic := Diagnostic{}
+			for i, name := range pattern.SubexpNames() {
+				switch name {
+				case "line":
+					diagnostic.Line, _ = strconv.Atoi(match[i])
+				case "column":
+					diagnostic.Column, _ = strconv.Atoi(match[i])
+				case "message":
+					diagnostic.Message = strings.TrimSpace(match[i])
+				}
+			}
+			diagnostics = append(diagnostics, diagnostic)
+		}
+		return diagnostics
+	}
+}
@@ -0,0 +1,16 @@
+package syntax
+
+import "regexp"
+
+func init() {
+	// Without the project's dependencies tsc reports type errors too, only
+	// the TS1xxx codes are syntax errors.
+	parse := matchLines(regexp.MustCompile(`(?m)^\S+\((?P<line>\d+),(?P<column>\d+)\): error (?P<message>TS1\d{3}: .*)$`))
+
+	Register(Command("tsc", []string{".ts", ".tsx"},
+		func(output string) []Diagnostic {
+			return append([]Diagnostic{}, parse(output)...)
+		},
+		"--noEmit", "--noResolve", "--isolatedModules", "--skipLibCheck", "--jsx", "preserve", "{file}",
+	))
+}